@@ -1,31 +1,59 @@
 package avro
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	schemaregistry "github.com/Landoop/schema-registry"
 	"github.com/linkedin/goavro"
 )
 
 type cachedCodec struct {
-	done  chan struct{}
-	codec *goavro.Codec
-	err   error
+	done    chan struct{}
+	codec   *goavro.Codec
+	err     error
+	fetched time.Time
+}
+
+// CacheStats are the lookups of a SchemaCache so far.
+type CacheStats struct {
+	// Hits is the number of lookups answered from the cache.
+	Hits int64
+	// Misses is the number of lookups which fetched a schema from the
+	// registry, including refreshes of expired schemas.
+	Misses int64
 }
 
 // SchemaCache connects to the Confluent schema registry and maintains
 // a cached versions of Avro schemas and codecs.
 type SchemaCache struct {
-	client *schemaregistry.Client
+	// Accessed atomically, first to be 64-bit aligned on 32-bit platforms.
+	hits, misses int64
+
+	client     *schemaregistry.Client
+	httpClient *http.Client
+	url        string
 
 	mu               sync.RWMutex
 	codecsBySchemaID map[int]*cachedCodec
+	ttl              time.Duration
 }
 
+// registryTimeout bounds requests to the schema registry.
+const registryTimeout = 30 * time.Second
+
 // NewSchemaCache returns a new Cache instance
 func NewSchemaCache(url string) (*SchemaCache, error) {
-	client, err := schemaregistry.NewClient(url)
+	httpClient := &http.Client{Timeout: registryTimeout}
+	client, err := schemaregistry.NewClient(url, schemaregistry.UsingClient(httpClient))
 	if err != nil {
 		return nil, err
 	}
@@ -33,27 +61,60 @@ func NewSchemaCache(url string) (*SchemaCache, error) {
 	c := &SchemaCache{
 		codecsBySchemaID: make(map[int]*cachedCodec),
 		client:           client,
+		httpClient:       httpClient,
+		url:              strings.TrimSuffix(url, "/"),
 	}
 	return c, nil
 }
 
+// SetTTL makes cached schemas expire after ttl, so that they are fetched from
+// the registry again. A ttl of 0 caches schemas forever. SetTTL must be called
+// before the cache is used.
+func (c *SchemaCache) SetTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *SchemaCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadInt64(&c.hits),
+		Misses: atomic.LoadInt64(&c.misses),
+	}
+}
+
+// expired returns whether cc was fetched longer than the TTL ago. Codecs still
+// being fetched never expire.
+func (c *SchemaCache) expired(cc *cachedCodec) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	select {
+	case <-cc.done:
+		return time.Since(cc.fetched) >= c.ttl
+	default:
+		return false
+	}
+}
+
 // getCodecForSchemaID returns a goavro codec for transforming data.
 func (c *SchemaCache) getCodecForSchemaID(schemaID int) (codec *goavro.Codec, err error) {
 	c.mu.RLock()
 	cc, ok := c.codecsBySchemaID[schemaID]
 	c.mu.RUnlock()
-	if ok {
+	if ok && !c.expired(cc) {
+		atomic.AddInt64(&c.hits, 1)
 		<-cc.done
 		return cc.codec, cc.err
 	}
 
-	// Codec is not cached, grab exclusive lock and ensure no other
-	// goroutine started the process in-between.
+	// Codec is not cached or expired, grab exclusive lock and ensure no
+	// other goroutine started the process in-between.
 	c.mu.Lock()
 	cc, ok = c.codecsBySchemaID[schemaID]
-	if ok {
+	if ok && !c.expired(cc) {
 		// Another goroutine began fetching schema and codec.
 		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
 		<-cc.done
 		return cc.codec, cc.err
 	}
@@ -62,10 +123,12 @@ func (c *SchemaCache) getCodecForSchemaID(schemaID int) (codec *goavro.Codec, er
 	cc = &cachedCodec{done: make(chan struct{})}
 	c.codecsBySchemaID[schemaID] = cc
 	c.mu.Unlock()
+	atomic.AddInt64(&c.misses, 1)
 
 	defer func() {
 		cc.codec = codec
-		cc.err = err   // Any failure is permanent on a per-schema basis.
+		cc.err = err // Any failure is permanent until the schema expires.
+		cc.fetched = time.Now()
 		close(cc.done) // Promise fulfilled.
 	}()
 
@@ -92,13 +155,30 @@ func (c *SchemaCache) DecodeMessage(b []byte) (message []byte, err error) {
 
 	// Schema ID is stored in the 4 bytes following the magic byte.
 	schemaID := binary.BigEndian.Uint32(b[1:5])
-	codec, err := c.getCodecForSchemaID(int(schemaID))
+	return c.decode(b, b[5:], int(schemaID))
+}
+
+// DecodeMessageWithSchema returns a text representation of an Avro-encoded
+// message. Messages with the schema registry header are decoded with the
+// schema whose ID is embedded in the header, all others are decoded as plain
+// Avro binary data with the schema of the given ID.
+func (c *SchemaCache) DecodeMessageWithSchema(b []byte, schemaID int) (message []byte, err error) {
+	if len(b) >= 5 && b[0] == 0x00 {
+		return c.DecodeMessage(b)
+	}
+	return c.decode(b, b, schemaID)
+}
+
+// decode decodes the Avro binary data of message b with the schema of the
+// given ID. b is returned on failure.
+func (c *SchemaCache) decode(b, data []byte, schemaID int) (message []byte, err error) {
+	codec, err := c.getCodecForSchemaID(schemaID)
 	if err != nil {
 		return b, err
 	}
 
 	// Convert binary Avro data back to native Go form
-	native, _, err := codec.NativeFromBinary(b[5:])
+	native, _, err := codec.NativeFromBinary(data)
 	if err != nil {
 		return b, err
 	}
@@ -111,3 +191,88 @@ func (c *SchemaCache) DecodeMessage(b []byte) (message []byte, err error) {
 
 	return message, nil
 }
+
+// SchemaByID returns the schema registered with the given ID.
+func (c *SchemaCache) SchemaByID(schemaID int) (string, error) {
+	return c.client.GetSchemaById(schemaID)
+}
+
+// SubjectSchemaID returns the ID of a version of the schema registered under
+// subject. Version 0 selects the latest version.
+func (c *SchemaCache) SubjectSchemaID(subject string, version int) (int, error) {
+	var schema schemaregistry.Schema
+	var err error
+	if version == 0 {
+		schema, err = c.client.GetLatestSchema(subject)
+	} else {
+		schema, err = c.client.GetSchemaBySubject(subject, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return schema.ID, nil
+}
+
+// EncodeMessage encodes textual Avro data (JSON) with the schema of the given
+// ID and prepends the Confluent wire format header.
+func (c *SchemaCache) EncodeMessage(schemaID int, textual []byte) ([]byte, error) {
+	codec, err := c.getCodecForSchemaID(schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.NativeFromTextual(textual)
+	if err != nil {
+		return nil, err
+	}
+
+	// Magic byte followed by the 4 byte schema ID.
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:5], uint32(schemaID))
+
+	return codec.BinaryFromNative(header, native)
+}
+
+// RegisterSchema registers schema under subject and returns its ID. If the
+// schema is already registered, the existing ID is returned.
+func (c *SchemaCache) RegisterSchema(subject, schema string) (int, error) {
+	return c.client.RegisterNewSchema(subject, schema)
+}
+
+// CheckCompatibility checks whether schema is compatible with the latest
+// version registered under subject, according to the compatibility level of
+// the subject. A schema for a subject without versions is always compatible.
+func (c *SchemaCache) CheckCompatibility(subject, schema string) (bool, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return false, err
+	}
+
+	endpoint := fmt.Sprintf("%v/compatibility/subjects/%v/versions/latest", c.url, url.PathEscape(subject))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("schema registry returned %v", resp.Status)
+	}
+
+	var result struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.IsCompatible, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/birdayz/kaf"
+)
+
+// authFile holds credentials which are kept out of the command line and the
+// config file. It is read from YAML or JSON.
+type authFile struct {
+	SASLUsername       string `yaml:"sasl_username"`
+	SASLPassword       string `yaml:"sasl_password"`
+	SchemaRegistryUser string `yaml:"schema_registry_user"`
+	SchemaRegistryPass string `yaml:"schema_registry_pass"`
+	TLSCAFile          string `yaml:"tls_cafile"`
+	TLSCertFile        string `yaml:"tls_certfile"`
+	TLSKeyFile         string `yaml:"tls_keyfile"`
+	OAuthToken         string `yaml:"oauth_token"`
+	OAuthClientSecret  string `yaml:"oauth_client_secret"`
+}
+
+// readAuthFile reads the credentials file at path. Files readable by other
+// users are refused, files accessible by the group cause a warning.
+func readAuthFile(path string) (*authFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch perm := info.Mode().Perm(); {
+	case perm&0004 != 0:
+		return nil, fmt.Errorf("%v is readable by other users, restrict its permissions to 0600", path)
+	case perm&0077 != 0:
+		fmt.Fprintf(os.Stderr, "Warning: %v has permissions %v, it should be 0600\n", path, perm)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so both formats are handled by the YAML decoder.
+	var auth authFile
+	if err := yaml.UnmarshalStrict(data, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// apply merges the credentials into cluster. Only fields set in the file
+// are changed.
+func (a *authFile) apply(cluster *kaf.Cluster) error {
+	if a.SASLUsername != "" || a.SASLPassword != "" {
+		if cluster.SASL == nil {
+			cluster.SASL = &kaf.SASL{}
+		}
+		if a.SASLUsername != "" {
+			cluster.SASL.Username = a.SASLUsername
+		}
+		if a.SASLPassword != "" {
+			cluster.SASL.Password = a.SASLPassword
+		}
+	}
+
+	if a.OAuthToken != "" || a.OAuthClientSecret != "" {
+		if cluster.SASL == nil {
+			cluster.SASL = &kaf.SASL{}
+		}
+		if cluster.SASL.OAuth == nil {
+			cluster.SASL.OAuth = &kaf.OAuth{}
+		}
+		if a.OAuthToken != "" {
+			oauth := cluster.SASL.OAuth
+			oauth.Token, oauth.TokenCommand, oauth.TokenEndpoint = a.OAuthToken, "", ""
+		}
+		if a.OAuthClientSecret != "" {
+			cluster.SASL.OAuth.ClientSecret = a.OAuthClientSecret
+		}
+	}
+
+	if a.SchemaRegistryUser != "" {
+		if cluster.SchemaRegistryURL == "" {
+			return fmt.Errorf("schema registry credentials given, but no schema registry is configured")
+		}
+		u, err := url.Parse(cluster.SchemaRegistryURL)
+		if err != nil {
+			return err
+		}
+		u.User = url.UserPassword(a.SchemaRegistryUser, a.SchemaRegistryPass)
+		cluster.SchemaRegistryURL = u.String()
+	}
+
+	if a.TLSCAFile != "" || a.TLSCertFile != "" || a.TLSKeyFile != "" {
+		if cluster.TLS == nil {
+			cluster.TLS = &kaf.TLS{}
+		}
+		if a.TLSCAFile != "" {
+			cluster.TLS.Cafile = a.TLSCAFile
+		}
+		if a.TLSCertFile != "" {
+			cluster.TLS.Clientfile = a.TLSCertFile
+		}
+		if a.TLSKeyFile != "" {
+			cluster.TLS.Clientkeyfile = a.TLSKeyFile
+		}
+	}
+	return nil
+}
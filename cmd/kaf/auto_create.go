@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+var allowAutoCreateFlag bool
+
+func init() {
+	for _, cmd := range []*cobra.Command{consumeCmd, produceCmd} {
+		cmd.Flags().BoolVar(&allowAutoCreateFlag, "allow-auto-create", false, "Allow the broker to create the topic if it does not exist, if auto.create.topics.enable is set. By default, missing topics are an error")
+	}
+}
+
+// checkTopicExists exits if topic does not exist, unless --allow-auto-create
+// is set. The client must have fetched the metadata of all topics, as
+// requesting the metadata of a missing topic creates it on brokers with
+// auto.create.topics.enable. The version of sarama kaf is built with can
+// not disable this in the request itself.
+func checkTopicExists(client sarama.Client, topic string) {
+	if allowAutoCreateFlag {
+		return
+	}
+	topics, err := client.Topics()
+	if err != nil {
+		errorExit("Unable to list topics: %v\n", err)
+	}
+	for _, t := range topics {
+		if t == topic {
+			return
+		}
+	}
+	errorExit("Topic %v does not exist. Use --allow-auto-create to let the broker create it\n", topic)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.BashCompletionFunction = bashCompletionFunc
+}
+
+// bashCompletionFunc completes topic and group names of commands taking them
+// as argument, by listing them from the current cluster.
+const bashCompletionFunc = `__kaf_get_topics()
+{
+    local kaf_output
+    if kaf_output=$(kaf topic ls --no-headers --compact 2>/dev/null); then
+        COMPREPLY=( $( compgen -W "${kaf_output[*]}" -- "$cur" ) )
+    fi
+}
+
+__kaf_get_groups()
+{
+    local kaf_output
+    if kaf_output=$(kaf group ls --no-headers --compact 2>/dev/null); then
+        COMPREPLY=( $( compgen -W "${kaf_output[*]}" -- "$cur" ) )
+    fi
+}
+
+__kaf_custom_func() {
+    case ${last_command} in
+        kaf_consume | kaf_produce | kaf_topic_describe | kaf_topic_delete | kaf_query_offset)
+            __kaf_get_topics
+            return
+            ;;
+        kaf_group_describe | kaf_group_delete | kaf_group_members)
+            __kaf_get_groups
+            return
+            ;;
+        *)
+            ;;
+    esac
+}
+`
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script for bash, zsh or PowerShell and print
+it to stdout.
+
+Bash:
+
+  # Load completions in the current shell (requires bash-completion):
+  source <(kaf completion bash)
+
+  # Load completions for every new shell:
+  kaf completion bash > /etc/bash_completion.d/kaf
+
+Bash completions include the names of topics and consumer groups of the
+current cluster.
+
+Zsh:
+
+  # Load completions for every new shell, assuming ~/.zsh/completions is
+  # part of $fpath:
+  kaf completion zsh > ~/.zsh/completions/_kaf
+
+PowerShell:
+
+  # Load completions in the current shell:
+  kaf completion powershell | Out-String | Invoke-Expression
+
+  # Load completions for every new shell, add the output to your profile:
+  kaf completion powershell >> $PROFILE
+
+Fish is not supported by the version of cobra kaf is built with.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "powershell"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "powershell":
+			err = rootCmd.GenPowerShellCompletion(os.Stdout)
+		default:
+			errorExit("Unsupported shell %v. Possible values: bash, zsh, powershell\n", args[0])
+		}
+		if err != nil {
+			errorExit("Unable to generate completion script: %v\n", err)
+		}
+	},
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/birdayz/kaf"
 	"github.com/manifoldco/promptui"
@@ -15,6 +17,8 @@ func init() {
 	configCmd.AddCommand(configLsCmd)
 	configCmd.AddCommand(configAddClusterCmd)
 	configCmd.AddCommand(configSelectCluster)
+	configCmd.AddCommand(configCurrentContextCmd)
+	configCmd.AddCommand(configGetContextsCmd)
 	rootCmd.AddCommand(configCmd)
 }
 
@@ -32,7 +36,7 @@ var configUseCmd = &cobra.Command{
 		if err := config.SetCurrentCluster(name); err != nil {
 			fmt.Printf("Cluster with name %v not found\n", name)
 		} else {
-			fmt.Printf("Switched to cluster \"%v\".\n", name)
+			printInfo("Switched to cluster \"%v\".\n", name)
 		}
 	},
 }
@@ -49,6 +53,42 @@ var configLsCmd = &cobra.Command{
 	},
 }
 
+var configCurrentContextCmd = &cobra.Command{
+	Use:   "current-context",
+	Short: "Display the active cluster and its brokers",
+	Long:  "Display the active cluster and its brokers. The active cluster is selected by the --cluster flag, the $KAF_CLUSTER environment variable or the current cluster of the configuration file, in that order.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := currentCluster.Name
+		if name == "" {
+			name = "(default)"
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "Name:\t%v\t\n", name)
+		fmt.Fprintf(w, "Brokers:\t%v\t\n", strings.Join(currentCluster.Brokers, ","))
+		w.Flush()
+	},
+}
+
+var configGetContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "Display clusters in the configuration file, marking the active one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "CURRENT\tNAME\tBROKERS\t\n")
+		for _, cluster := range config.Clusters {
+			var marker string
+			if cluster.Name == currentCluster.Name {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", marker, cluster.Name, strings.Join(cluster.Brokers, ","))
+		}
+		w.Flush()
+	},
+}
+
 var configSelectCluster = &cobra.Command{
 	Use:   "select-cluster",
 	Short: "Interactively select a cluster",
@@ -90,7 +130,7 @@ var configAddClusterCmd = &cobra.Command{
 		if err != nil {
 			errorExit("Unable to write config: %v\n", err)
 		}
-		fmt.Println("Added cluster.")
+		printInfo("Added cluster.\n")
 	},
 }
 
@@ -99,7 +139,7 @@ var configImportCmd = &cobra.Command{
 	Short: "Import configurations into the $HOME/.kaf/config file",
 	Run: func(cmd *cobra.Command, args []string) {
 		if path, err := kaf.TryFindCcloudConfigFile(); err == nil {
-			fmt.Printf("Detected Confluent Cloud config in file %v\n", path)
+			printInfo("Detected Confluent Cloud config in file %v\n", path)
 			if username, password, broker, err := kaf.ParseConfluentCloudConfig(path); err == nil {
 
 				newCluster := &kaf.Cluster{
@@ -123,7 +163,7 @@ var configImportCmd = &cobra.Command{
 				}
 
 				if !found {
-					fmt.Println("Wrote new entry to config file")
+					printInfo("Wrote new entry to config file\n")
 					config.Clusters = append(config.Clusters, newCluster)
 				}
 
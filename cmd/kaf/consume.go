@@ -2,18 +2,21 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Shopify/sarama"
 	"github.com/birdayz/kaf/avro"
 	prettyjson "github.com/hokaccha/go-prettyjson"
-	colorable "github.com/mattn/go-colorable"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +26,84 @@ var (
 	follow      bool
 	schemaCache *avro.SchemaCache
 	keyfmt      *prettyjson.Formatter
+	valuefmt    *prettyjson.Formatter
+	themeFlag   string
+
+	orderByTime     bool
+	orderBufferSize int
+	orderWindow     time.Duration
+
+	outputFlag string
+	limitFlag  int64
+	fair       bool
+
+	nullMarkerFlag  string
+	emptyMarkerFlag string
+
+	decodeErrorsFlag string
+	skippedMessages  int64
+
+	skipFlag int64
+
+	groupFlag             string
+	commitIntervalFlag    time.Duration
+	rebalanceStrategyFlag string
+
+	printOffsetsOnlyFlag bool
+
+	fetchMinBytesFlag     int32
+	fetchDefaultBytesFlag int32
+	maxWaitFlag           time.Duration
+
+	filterFlags  []string
+	selectFlag   string
+	valueFilters []*valueFilter
+	selectPath   jsonPath
+
+	topFlag        int
+	topMaxKeysFlag int
+	keyCounts      *keyCounter
+
+	histogramFlag bool
+	sizes         *sizeHistogram
+
+	flattenFlag     bool
+	compactJSONFlag bool
+
+	metadataRefreshFlag time.Duration
+
+	dedupFlag       bool
+	dedupByFlag     string
+	dedupWindowFlag time.Duration
+	dedupSizeFlag   int
+	dedup           *deduplicator
+
+	valueSchemaSubjectFlag string
+	valueSchemaVersionFlag int
+	valueSchemaID          int
+
+	endOffsetFlag        string
+	exitOnLastOffsetFlag bool
+	drained              = newDrainProgress()
+
+	batchFlag int
+	output    *batchedOutput
+
+	headersAsJSONFlag bool
+
+	sinceCommitFlag string
+	noCommitFlag    bool
+
+	rawKeyFlag      bool
+	kvSeparatorFlag string
+
+	lastFlag string
+
+	stopOnEOFFlag bool
+	tailFlag      int64
+
+	printKeyHashFlag  bool
+	keyHashPartitions int32
 )
 
 func init() {
@@ -30,10 +111,59 @@ func init() {
 	consumeCmd.Flags().StringVar(&offsetFlag, "offset", "oldest", "Offset to start consuming. Possible values: oldest, newest.")
 	consumeCmd.Flags().BoolVar(&raw, "raw", false, "Print raw output of messages, without key or prettified JSON")
 	consumeCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Shorthand to start consuming with offset HEAD-1 on each partition. Overrides --offset flag")
+	consumeCmd.Flags().StringVarP(&outputFlag, "output", "o", "default", "Output format. Possible values: default, raw, json, csv. raw is the same as --raw, json prints one JSON object per message, csv one row per message with the columns of --columns.")
+	consumeCmd.Flags().Int64Var(&limitFlag, "limit", 0, "Stop after printing this many messages. 0 means no limit")
+	consumeCmd.Flags().Int64Var(&skipFlag, "skip", 0, "Skip this many messages on each partition before printing. Combine with --limit to print a window of messages")
+	consumeCmd.Flags().StringVarP(&groupFlag, "group", "g", "", "Consume as a member of this consumer group, starting from and committing its offsets. --offset applies if the group has no committed offset")
+	consumeCmd.Flags().StringVar(&rebalanceStrategyFlag, "rebalance-strategy", "range", "Partition assignment strategy of --group. Possible values: range, roundrobin. All members of a group must support the strategy, otherwise the broker rejects joining the group")
+	consumeCmd.Flags().DurationVar(&commitIntervalFlag, "commit-interval", time.Second, "How often to commit offsets with --group. Offsets are also committed on exit")
+	consumeCmd.Flags().BoolVar(&printOffsetsOnlyFlag, "print-offsets-only", false, "Only print partition, offset and timestamp of each message, tab separated. Keys and values are not decoded")
+	consumeCmd.Flags().BoolVar(&fair, "fair", false, "Read at most one message of each partition per round, so that --limit samples evenly across partitions")
+	consumeCmd.Flags().StringArrayVar(&filterFlags, "filter", nil, "Only print messages whose value is JSON with an element matching a regex, given as <jsonpath>=<regex>, e.g. '$.user.name=^bob'. May be repeated, all filters must match")
+	consumeCmd.Flags().StringVar(&selectFlag, "select", "", "Only print the element of JSON values at this JSONPath, e.g. '$.items[0].id'. Messages without it are skipped")
+	consumeCmd.Flags().BoolVar(&flattenFlag, "flatten", false, "Print one line path=value per leaf of JSON values, e.g. a.b[0]=1. Applied after decoding, --filter and --select. Values which are not JSON are printed as they are")
+	consumeCmd.Flags().BoolVar(&compactJSONFlag, "compact-json", false, "Print JSON values minified on a single line, e.g. to grep them. Values which are not JSON are printed as they are")
+	consumeCmd.Flags().BoolVar(&histogramFlag, "histogram", false, "Instead of printing messages, print the distribution of value sizes in bytes once consuming stops, e.g. on interrupt, --limit or --end-offset. Prints JSON with --output json")
+	consumeCmd.Flags().IntVar(&topFlag, "top", 0, "Instead of printing messages, count messages per key and print the N keys with the most messages once consuming stops, e.g. on interrupt or --limit")
+	consumeCmd.Flags().IntVar(&topMaxKeysFlag, "top-max-keys", 100000, "Maximum number of distinct keys tracked by --top, to bound memory. 0 means no limit")
+	consumeCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers of --top and --histogram and the header row of --output csv")
+	consumeCmd.Flags().BoolVar(&dedupFlag, "dedup", false, "Suppress messages whose key was already printed within --dedup-window")
+	consumeCmd.Flags().StringVar(&dedupByFlag, "dedup-by", "key", "What identifies duplicates with --dedup. Possible values: key, value")
+	consumeCmd.Flags().DurationVar(&dedupWindowFlag, "dedup-window", time.Minute, "Time after which a duplicate is printed again with --dedup, measured by message timestamps")
+	consumeCmd.Flags().IntVar(&dedupSizeFlag, "dedup-size", 10000, "Maximum number of keys or values remembered by --dedup")
+	consumeCmd.Flags().StringVar(&valueSchemaSubjectFlag, "value-schema-subject", "", "Decode values without a schema registry header as Avro with the schema registered under this subject. Values with a header are still decoded with the schema it references")
+	consumeCmd.Flags().IntVar(&valueSchemaVersionFlag, "value-schema-version", 0, "Version of --value-schema-subject to use (default latest)")
+	consumeCmd.Flags().StringVar(&themeFlag, "theme", "default", "Color theme of formatted JSON keys and values. Possible values: default, solarized, mono (no colors)")
+	consumeCmd.Flags().StringVar(&endOffsetFlag, "end-offset", "", "Offset to stop consuming at. Possible values: newest-at-start (stop once all messages present at startup were consumed)")
+	consumeCmd.Flags().BoolVar(&exitOnLastOffsetFlag, "exit-on-last-offset-reached", false, fmt.Sprintf("Implies --end-offset newest-at-start. Prints the reached offset of each partition on exit and exits with code %v if consuming stopped before all partitions were drained, e.g. on interrupt", drainIncompleteExitCode))
+	consumeCmd.Flags().BoolVar(&headersAsJSONFlag, "headers-as-json", false, "Print headers as a single JSON object, which produce --headers-json accepts. Binary values are represented as {\"b64\": \"...\"}")
+	consumeCmd.Flags().StringVar(&sinceCommitFlag, "since-commit", "", "Consume from the committed offsets of this group up to the newest offsets at startup, then commit the reached offsets for the group and exit. The group must not have active members. --offset applies to partitions without committed offset")
+	consumeCmd.Flags().BoolVar(&noCommitFlag, "no-commit", false, "Do not commit offsets with --since-commit")
+	consumeCmd.Flags().BoolVar(&rawKeyFlag, "raw-key", false, "Print key and value of each message on one line, separated by --kv-separator. Implies --raw. Keys and values which are not valid UTF-8 are base64 encoded, null keys are printed as empty string")
+	consumeCmd.Flags().StringVar(&kvSeparatorFlag, "kv-separator", "\t", "Separator between key and value with --raw-key")
+	consumeCmd.Flags().BoolVar(&printKeyHashFlag, "print-key-hash", false, "Print the murmur2 hash of each key and the partition the default partitioner of the Java client assigns it to, marking messages found in a different partition")
+	consumeCmd.Flags().Int64Var(&tailFlag, "tail", 0, "Start consuming each partition this many messages before its end, then keep consuming new messages. Overrides --offset and --follow")
+	consumeCmd.Flags().BoolVar(&stopOnEOFFlag, "stop-on-eof", false, "Stop consuming each partition once it caught up with its current end, detected while consuming instead of from the offsets at startup. Exits once all partitions reached their end")
+	consumeCmd.Flags().StringVar(&lastFlag, "last", "", "Start consuming at the first message of each partition produced within this duration, e.g. 30m, 1h or 2d. Overrides --offset. Combine with --end-offset newest-at-start to print a bounded window")
+	consumeCmd.Flags().IntVar(&batchFlag, "batch", 64, "Number of messages of a partition buffered before they are printed at once. Buffered messages are printed at least every 100ms. 1 disables buffering")
+	consumeCmd.Flags().StringVar(&nullMarkerFlag, "null-marker", "<null>", "Printed instead of the value of messages with a null value, e.g. tombstones")
+	consumeCmd.Flags().StringVar(&emptyMarkerFlag, "empty-marker", "", "Printed instead of the value of messages with an empty, but not null value")
+	consumeCmd.Flags().StringVar(&decodeErrorsFlag, "decode-errors", "raw", "What to do with messages which can not be decoded. Possible values: raw (print undecoded bytes), skip (drop the message), fail (stop consuming)")
+	consumeCmd.Flags().BoolVar(&orderByTime, "order-by-time", false, "Buffer messages of all partitions and print them sorted by timestamp")
+	consumeCmd.Flags().IntVar(&orderBufferSize, "order-buffer", 1000, "Maximum number of messages buffered with --order-by-time")
+	consumeCmd.Flags().DurationVar(&orderWindow, "order-window", time.Second, "Maximum time a message is buffered with --order-by-time")
+
+	defaults := sarama.NewConfig()
+	consumeCmd.Flags().Int32Var(&fetchMinBytesFlag, "fetch-min-bytes", defaults.Consumer.Fetch.Min, "Minimum number of bytes a broker collects before answering a fetch request. Raising it, together with --max-wait, reduces the number of requests when draining large topics at the cost of latency")
+	consumeCmd.Flags().Int32Var(&fetchDefaultBytesFlag, "fetch-bytes", defaults.Consumer.Fetch.Default, "Number of bytes fetched per partition and request. Larger fetches speed up draining large topics but use more memory")
+	consumeCmd.Flags().DurationVar(&maxWaitFlag, "max-wait", defaults.Consumer.MaxWaitTime, "Maximum time a broker waits for --fetch-min-bytes to become available. Higher values mean fewer requests, but new messages may be printed up to this much later")
+	consumeCmd.Flags().DurationVar(&metadataRefreshFlag, "metadata-refresh", defaults.Metadata.RefreshFrequency, "Interval in which the cluster metadata is refreshed in the background, so that new partition leaders are picked up during long running consumes")
 
 	keyfmt = prettyjson.NewFormatter()
 	keyfmt.Newline = " " // Replace newline with space to avoid condensed output.
 	keyfmt.Indent = 0
+
+	valuefmt = prettyjson.NewFormatter()
 }
 
 func getAvailableOffsetsRetry(
@@ -64,6 +194,9 @@ var consumeCmd = &cobra.Command{
 	Short: "Consume messages",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		s := validateConsumeFlags(cmd)
+		idPath, hasSinceID, hasUntilID := s.idPath, s.hasSinceID, s.hasUntilID
+		splitExt, offsetMap, last := s.splitExt, s.offsetMap, s.last
 
 		var offset int64
 		switch offsetFlag {
@@ -79,120 +212,550 @@ var consumeCmd = &cobra.Command{
 			offset = sarama.OffsetNewest
 		}
 		topic := args[0]
-		client := getClient()
 
-		consumer, err := sarama.NewConsumerFromClient(client)
-		if err != nil {
-			errorExit("Unable to create consumer from client: %v\n", err)
+		cfg := getConfig()
+		cfg.Consumer.Fetch.Min = fetchMinBytesFlag
+		cfg.Consumer.Fetch.Default = fetchDefaultBytesFlag
+		cfg.Consumer.MaxWaitTime = maxWaitFlag
+		cfg.Metadata.RefreshFrequency = metadataRefreshFlag
+		cfg.Consumer.Return.Errors = true
+		if groupFlag != "" {
+			cfg.Consumer.Offsets.Initial = offset
+			cfg.Consumer.Offsets.CommitInterval = commitIntervalFlag
+			strategy, err := parseRebalanceStrategy(rebalanceStrategyFlag)
+			if err != nil {
+				errorExit("%v\n", err)
+			}
+			cfg.Consumer.Group.Rebalance.Strategy = strategy
+		} else if cmd.Flags().Changed("commit-interval") {
+			errorExit("--commit-interval requires --group\n")
+		} else if cmd.Flags().Changed("rebalance-strategy") {
+			errorExit("--rebalance-strategy requires --group\n")
+		} else if groupGenerationFlag {
+			errorExit("--group-generation requires --group\n")
 		}
+		client := getClientFromConfig(cfg)
+		checkTopicExists(client, topic)
 
-		partitions, err := consumer.Partitions(topic)
-		if err != nil {
-			errorExit("Unable to get partitions: %v\n", err)
+		if printKeyHashFlag || idPath != nil {
+			partitions, err := client.Partitions(topic)
+			if err != nil {
+				errorExit("Unable to get partitions: %v\n", err)
+			}
+			if printKeyHashFlag {
+				keyHashPartitions = int32(len(partitions))
+			}
+			if idPath != nil {
+				ids = newIDRange(idPath, len(partitions))
+				ids.since, ids.hasSince = sinceIDFlag, hasSinceID
+				ids.until, ids.hasUntil = untilIDFlag, hasUntilID
+			}
 		}
 
 		schemaCache = getSchemaCache()
+		configureSchemaCache()
+		resolveValueSchemaSubject()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			stopConsuming()
+		}()
+		if exitOnLastOffsetFlag {
+			// Deferred first, so that it runs after all output was written.
+			defer func() {
+				if !drained.report(endOffsets) {
+					os.Exit(drainIncompleteExitCode)
+				}
+			}()
+		}
+		if validateJSONFlag {
+			defer exitOnInvalidJSON()
+		}
+		defer printConsumeSummary()
 
-		wg := sync.WaitGroup{}
 		mu := sync.Mutex{} // Synchronizes stderr and stdout.
-		for _, partition := range partitions {
 
-			wg.Add(1)
+		// Output ordered across partitions, by time or round-robin with
+		// --fair, must not be split into per-partition batches.
+		output = newBatchedOutput(&mu, batchFlag, !orderByTime && !fair)
+		if splitByPartitionFlag != "" {
+			if err := output.splitByPartition(splitByPartitionFlag, splitExt); err != nil {
+				errorExit("Unable to create output directory: %v\n", err)
+			}
+		}
+		output.closeOnExit()
+		defer closeExitOutput()
+		if batchFlag > 1 {
+			go output.flushEvery(outputFlushInterval, stopConsume)
+		}
 
-			go func(partition int32) {
-				req := &sarama.OffsetRequest{
-					Version: int16(1),
-				}
-				req.AddBlock(topic, partition, int64(-1), int32(0))
-				ldr, err := client.Leader(topic, partition)
-				if err != nil {
-					errorExit("Unable to get leader: %v\n", err)
-				}
+		if outputFlag == "csv" && !noHeaderFlag && keyCounts == nil && sizes == nil && !printOffsetsOnlyFlag {
+			header, err := csvRow(csvColumns)
+			if err != nil {
+				errorExit("Unable to encode CSV header: %v\n", err)
+			}
+			os.Stdout.Write(header)
+		}
 
-				offsets, err := getAvailableOffsetsRetry(ldr, req, offsetsRetry)
-				if err != nil {
-					errorExit("Unable to get available offsets: %v\n", err)
-				}
-				followOffset := offsets.GetBlock(topic, partition).Offset - 1
+		var orderer *timeOrderer
+		if orderByTime {
+			orderer = newTimeOrderer(orderBufferSize, orderWindow, handleMessage)
+			defer orderer.Close()
+		}
 
-				if follow && followOffset > 0 {
-					offset = followOffset
-					fmt.Fprintf(os.Stderr, "Starting on partition %v with offset %v\n", partition, offset)
-				}
+		skipped := newPartitionCounter()
+		emit := func(msg *sarama.ConsumerMessage) {
+			if skipFlag > 0 && skipped.inc(msg.Partition) <= skipFlag {
+				return
+			}
+			if orderer != nil {
+				orderer.Add(msg)
+			} else {
+				handleMessage(msg)
+			}
+		}
 
-				pc, err := consumer.ConsumePartition(topic, partition, offset)
-				if err != nil {
-					errorExit("Unable to consume partition: %v\n", err)
-				}
+		if groupFlag != "" {
+			consumeGroup(client, groupFlag, topic, emit)
+			return
+		}
 
-				for msg := range pc.Messages() {
-					var stderr bytes.Buffer
-
-					dataToDisplay, err := avroDecode(msg.Value)
-					if err != nil {
-						fmt.Fprintf(&stderr, "could not decode Avro data: %v\n", err)
-					}
-
-					if !raw {
-						formatted, err := prettyjson.Format(dataToDisplay)
-						if err == nil {
-							dataToDisplay = formatted
-						}
-
-						w := tabwriter.NewWriter(&stderr, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
-
-						if len(msg.Headers) > 0 {
-							fmt.Fprintf(w, "Headers:\n")
-						}
-
-						for _, hdr := range msg.Headers {
-							var hdrValue string
-							// Try to detect azure eventhub-specific encoding
-							if len(hdr.Value) > 0 {
-								switch hdr.Value[0] {
-								case 161:
-									hdrValue = string(hdr.Value[2 : 2+hdr.Value[1]])
-								case 131:
-									hdrValue = strconv.FormatUint(binary.BigEndian.Uint64(hdr.Value[1:9]), 10)
-								default:
-									hdrValue = string(hdr.Value)
-								}
-							}
-
-							fmt.Fprintf(w, "\tKey: %v\tValue: %v\n", string(hdr.Key), hdrValue)
-
-						}
-
-						if msg.Key != nil && len(msg.Key) > 0 {
-							key, err := avroDecode(msg.Key)
-							if err != nil {
-								fmt.Fprintf(&stderr, "could not decode Avro data: %v\n", err)
-							}
-							fmt.Fprintf(w, "Key:\t%v\n", formatKey(key))
-						}
-						fmt.Fprintf(w, "Partition:\t%v\nOffset:\t%v\nTimestamp:\t%v\n", msg.Partition, msg.Offset, msg.Timestamp)
-						w.Flush()
-					}
-
-					mu.Lock()
-					stderr.WriteTo(os.Stderr)
-					colorable.NewColorableStdout().Write(dataToDisplay)
-					fmt.Print("\n")
-					mu.Unlock()
-				}
-				wg.Done()
-			}(partition)
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			errorExit("Unable to get partitions: %v\n", err)
+		}
+
+		if offsetMap != nil {
+			consumeOffsetMap(client, topic, partitions, offsetMap, eofIdle(cfg), emit)
+			return
+		}
+		if reverseFlag {
+			consumeReverse(client, topic, partitions, emit)
+			return
+		}
+		if groupSnapshotFlag != "" {
+			consumeGroupSnapshot(client, topic, partitions, groupSnapshotFlag, emit)
+			return
 		}
-		wg.Wait()
 
+		so := startOptions{
+			offset: offset,
+			follow: follow,
+			tail:   tailFlag,
+			group:  sinceCommitFlag,
+			// Consuming up to an end offset requires exact start offsets.
+			exact: endOffsetFlag != "",
+		}
+		if last > 0 {
+			so.since = time.Now().Add(-last)
+		}
+		starts, highWatermarks, err := resolveStarts(client, topic, partitions, so)
+		if err != nil {
+			errorExit("%v\n", err)
+		}
+		if endOffsetFlag != "" {
+			endOffsets = highWatermarks
+			for partition, offset := range starts {
+				drained.set(partition, offset)
+			}
+		}
+
+		opts := readOptions{start: starts, end: endOffsets, fair: fair, stop: stopConsume, eofIdle: eofIdle(cfg)}
+		if endOffsets != nil {
+			// The offsets before the end offset may be transaction
+			// markers, which are never delivered as messages.
+			opts.caughtUp = func(partition int32) { drained.set(partition, endOffsets[partition]) }
+		}
+		emitDrained := func(msg *sarama.ConsumerMessage) {
+			emit(msg)
+			if endOffsets != nil {
+				drained.set(msg.Partition, msg.Offset+1)
+			}
+		}
+		if workersFlag > 0 {
+			err = consumeWorkers(client, topic, partitions, opts, emitDrained)
+		} else {
+			err = consumePartitions(client, topic, partitions, opts, emitDrained)
+		}
+		if err != nil {
+			errorExit("Unable to consume partition: %v\n", err)
+		}
+
+		if sinceCommitFlag != "" && !noCommitFlag {
+			// Only commit once everything consumed was written.
+			output.flush()
+			if err := commitGroupOffsets(client, sinceCommitFlag, topic, drained.offsets()); err != nil {
+				errorExit("Unable to commit offsets of group %v: %v\n", sinceCommitFlag, err)
+			}
+			printNotice("Committed offsets of group %v.\n", sinceCommitFlag)
+		}
 	},
 }
 
-func avroDecode(b []byte) ([]byte, error) {
-	if schemaCache != nil {
-		return schemaCache.DecodeMessage(b)
+// eofIdle returns how long a partition must not deliver messages until it is
+// considered caught up with --stop-on-eof or --end-offset, or 0 otherwise.
+// With --end-offset, this ends partitions whose last offsets before the end
+// offset are transaction markers.
+func eofIdle(cfg *sarama.Config) time.Duration {
+	if !stopOnEOFFlag && endOffsets == nil {
+		return 0
+	}
+	return fetchIdleTimeout(cfg)
+}
+
+// fetchIdleTimeout returns the time after which a partition without messages
+// is caught up. As fetches return as soon as messages are available, a few
+// fetches coming back empty mean that there are no more messages.
+func fetchIdleTimeout(cfg *sarama.Config) time.Duration {
+	idle := 4 * cfg.Consumer.MaxWaitTime
+	if idle < time.Second {
+		idle = time.Second
+	}
+	return idle
+}
+
+// endOffsets holds the high watermarks at startup of all partitions if
+// consuming stops at --end-offset newest-at-start.
+var endOffsets map[int32]int64
+
+var (
+	stopConsume     = make(chan struct{})
+	stopConsumeOnce sync.Once
+	messageCount    int64
+)
+
+// stopConsuming signals all partition consumers to stop.
+func stopConsuming() {
+	stopConsumeOnce.Do(func() { close(stopConsume) })
+}
+
+// partitionCounter counts messages per partition.
+type partitionCounter struct {
+	mu     sync.Mutex
+	counts map[int32]int64
+}
+
+func newPartitionCounter() *partitionCounter {
+	return &partitionCounter{counts: make(map[int32]int64)}
+}
+
+// inc increments the count of partition and returns the new count.
+func (c *partitionCounter) inc(partition int32) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[partition]++
+	return c.counts[partition]
+}
+
+// printConsumeSummary prints statistics about the consumed messages to
+// stderr once consuming stopped.
+func printConsumeSummary() {
+	if keyCounts != nil {
+		keyCounts.printTop(topFlag)
+	}
+	if sizes != nil {
+		sizes.print()
+	}
+	if dedup != nil {
+		fmt.Fprintf(os.Stderr, "Suppressed %v duplicate messages.\n", dedup.suppressedCount())
+	}
+	if decodeErrorsFlag == "skip" {
+		fmt.Fprintf(os.Stderr, "Skipped %v messages which could not be decoded.\n", atomic.LoadInt64(&skippedMessages))
+	}
+	printMaxBytesSummary()
+	printSchemaCacheStats()
+	printValidateJSONSummary()
+}
+
+// countMessage accounts for a message about to be printed. It returns false
+// if the message exceeds --limit and must not be printed.
+func countMessage() bool {
+	if limitFlag <= 0 {
+		return true
+	}
+
+	n := atomic.AddInt64(&messageCount, 1)
+	if n >= limitFlag {
+		stopConsuming()
+	}
+	return n <= limitFlag
+}
+
+// outputFlushInterval is the maximum time messages are buffered with --batch.
+const outputFlushInterval = 100 * time.Millisecond
+
+func handleMessage(msg *sarama.ConsumerMessage) {
+	for _, filter := range headerFilters {
+		if !filter.matches(msg.Headers) {
+			return
+		}
+	}
+	if printOffsetsOnlyFlag {
+		// Skip decoding entirely, only the coordinates are printed.
+		if !countMessage() {
+			return
+		}
+		line := fmt.Sprintf("%v\t%v\t%v\n", msg.Partition, msg.Offset, formatTimestamp(msg.Timestamp, time.RFC3339Nano))
+		output.write(msg.Partition, nil, []byte(line))
+		return
+	}
+
+	var stderr bytes.Buffer
+
+	dataToDisplay, ok := decodeData(msg.Value, valueSchemaID, &stderr)
+	if !ok {
+		return
+	}
+
+	var key []byte
+	if outputFlag != "raw" || rawKeyFlag || keyCounts != nil || (dedup != nil && dedupByFlag == "key") {
+		key, ok = decodeData(msg.Key, 0, &stderr)
+		if !ok {
+			return
+		}
+	}
+
+	if ids != nil && !ids.contains(msg.Partition, msg.Offset, dataToDisplay) {
+		return
+	}
+	for _, filter := range valueFilters {
+		if !filter.matches(dataToDisplay) {
+			return
+		}
+	}
+	if selectPath != nil {
+		selected, ok := selectPath.extract(dataToDisplay)
+		if !ok {
+			return
+		}
+		dataToDisplay = []byte(selected)
+	}
+
+	flattened := false
+	if flattenFlag {
+		if lines, ok := flattenJSON(dataToDisplay); ok {
+			dataToDisplay, flattened = lines, true
+		}
+	}
+
+	if dedup != nil {
+		ts := msg.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		var id string
+		if dedupByFlag == "key" {
+			id = dedupID(key, false)
+		} else {
+			id = dedupID(dataToDisplay, true)
+		}
+		if dedup.seen(id, ts) {
+			return
+		}
+	}
+
+	if !countBytes(len(msg.Value)) || !countMessage() {
+		return
+	}
+
+	if validateJSONFlag {
+		validateJSON(msg, dataToDisplay)
+		return
+	}
+
+	if sizes != nil {
+		sizes.add(len(msg.Value))
+		return
+	}
+
+	if keyCounts != nil {
+		if msg.Key == nil {
+			keyCounts.add(nullMarkerFlag)
+		} else {
+			keyCounts.add(string(key))
+		}
+		return
+	}
+
+	if outputFlag == "csv" {
+		printCSVMessage(msg, key, dataToDisplay, &stderr)
+		return
+	}
+
+	if outputFlag == "json" {
+		if printKeyHashFlag {
+			fmt.Fprintf(&stderr, "Partition %v offset %v key hash: %v\n", msg.Partition, msg.Offset, keyRouting(msg))
+		}
+		if partitionWorkers != nil {
+			fmt.Fprintf(&stderr, "Partition %v offset %v worker: %v\n", msg.Partition, msg.Offset, partitionWorkers[msg.Partition])
+		}
+		printJSONMessage(msg, key, dataToDisplay, &stderr)
+		return
+	}
+
+	if compactJSONFlag {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, dataToDisplay); err == nil {
+			dataToDisplay = compacted.Bytes()
+		}
+	}
+
+	if outputFlag != "raw" {
+		if !flattened && !compactJSONFlag {
+			formatted, err := valuefmt.Format(dataToDisplay)
+			if err == nil {
+				dataToDisplay = formatted
+			}
+		}
+
+		w := tabwriter.NewWriter(&stderr, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+
+		switch {
+		case len(msg.Headers) == 0:
+		case headersAsJSONFlag:
+			headers, err := json.Marshal(newJSONHeaders(msg.Headers))
+			if err != nil {
+				fmt.Fprintf(w, "could not encode headers as JSON: %v\n", err)
+			}
+			fmt.Fprintf(w, "Headers:\t%s\n", headers)
+		default:
+			fmt.Fprintf(w, "Headers:\n")
+			for _, hdr := range msg.Headers {
+				// Try to detect azure eventhub-specific encoding
+				hdrValue := decodeHeaderValue(hdr.Value).text
+				fmt.Fprintf(w, "\tKey: %v\tValue: %v\n", string(hdr.Key), hdrValue)
+			}
+		}
+
+		if len(key) > 0 {
+			fmt.Fprintf(w, "Key:\t%v\n", formatKey(key))
+		}
+		if printKeyHashFlag {
+			fmt.Fprintf(w, "Key Hash:\t%v\n", keyRouting(msg))
+		}
+		if partitionWorkers != nil {
+			fmt.Fprintf(w, "Worker:\t%v\n", partitionWorkers[msg.Partition])
+		}
+		fmt.Fprintf(w, "Partition:\t%v\nOffset:\t%v\nTimestamp:\t%v\n", msg.Partition, msg.Offset, formatTimestamp(msg.Timestamp, ""))
+		w.Flush()
+	} else if printKeyHashFlag {
+		fmt.Fprintf(&stderr, "Partition %v offset %v key hash: %v\n", msg.Partition, msg.Offset, keyRouting(msg))
+	}
+	if outputFlag == "raw" && partitionWorkers != nil {
+		fmt.Fprintf(&stderr, "Partition %v offset %v worker: %v\n", msg.Partition, msg.Offset, partitionWorkers[msg.Partition])
+	}
+
+	switch {
+	case msg.Value == nil:
+		dataToDisplay = []byte(nullMarkerFlag)
+	case len(msg.Value) == 0:
+		dataToDisplay = []byte(emptyMarkerFlag)
+	}
+
+	if rawKeyFlag {
+		dataToDisplay = []byte(rawField(key) + kvSeparatorFlag + rawField(dataToDisplay))
+	}
+
+	// dataToDisplay may share the fetch buffer of the consumer, so it must
+	// not be appended to.
+	line := make([]byte, 0, len(dataToDisplay)+1)
+	line = append(append(line, dataToDisplay...), '\n')
+	output.write(msg.Partition, stderr.Bytes(), line)
+}
+
+// keyRouting describes the murmur2 hash of the key of msg and the partition
+// the Java client would route it to.
+func keyRouting(msg *sarama.ConsumerMessage) string {
+	if msg.Key == nil {
+		return "none (null key)"
+	}
+	expected := javaDefaultPartition(msg.Key, keyHashPartitions)
+	routing := fmt.Sprintf("%v (partition %v)", murmur2(msg.Key), expected)
+	if expected != msg.Partition {
+		routing += " MISMATCH"
+	}
+	return routing
+}
+
+// rawField formats a key or value for --raw-key. Binary data is base64
+// encoded.
+func rawField(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// printJSONMessage prints msg as a single line JSON object.
+func printJSONMessage(msg *sarama.ConsumerMessage, key, value []byte, stderr *bytes.Buffer) {
+	m := newJSONMessage(msg, key, value)
+	if sess, ok := currentGroupSession.Load().(groupSession); ok {
+		m.GroupGeneration = &sess.generation
+		m.MemberID = sess.member
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not encode message as JSON: %v\n", err)
+	}
+	if out != nil {
+		out = append(out, '\n')
+	}
+	output.write(msg.Partition, stderr.Bytes(), out)
+}
+
+// decodeData decodes a message key or value and applies the --decode-errors
+// policy if decoding fails. It returns false if the message must be skipped.
+// If schemaID is not 0, data without a schema registry header is decoded with
+// that schema.
+func decodeData(b []byte, schemaID int, stderr *bytes.Buffer) ([]byte, bool) {
+	decoded, err := avroDecode(b, schemaID)
+	if err == nil {
+		return decoded, true
+	}
+
+	switch decodeErrorsFlag {
+	case "skip":
+		atomic.AddInt64(&skippedMessages, 1)
+		return nil, false
+	case "fail":
+		// errorExit prints what was consumed so far.
+		errorExit("Could not decode Avro data: %v\n", err)
+	}
+
+	fmt.Fprintf(stderr, "could not decode Avro data: %v\n", err)
+	return b, true
+}
+
+func avroDecode(b []byte, schemaID int) ([]byte, error) {
+	if schemaCache == nil {
+		return b, nil
+	}
+	if schemaID != 0 && len(b) > 0 {
+		return schemaCache.DecodeMessageWithSchema(b, schemaID)
+	}
+	return schemaCache.DecodeMessage(b)
+}
+
+// resolveValueSchemaSubject looks up the schema selected via
+// --value-schema-subject and --value-schema-version.
+func resolveValueSchemaSubject() {
+	if valueSchemaSubjectFlag == "" {
+		if valueSchemaVersionFlag != 0 {
+			errorExit("--value-schema-version requires --value-schema-subject\n")
+		}
+		return
+	}
+	if schemaCache == nil {
+		errorExit("--value-schema-subject requires a schema registry\n")
+	}
+
+	var err error
+	valueSchemaID, err = schemaCache.SubjectSchemaID(valueSchemaSubjectFlag, valueSchemaVersionFlag)
+	if err != nil {
+		errorExit("Unable to get schema of subject %v: %v\n", valueSchemaSubjectFlag, err)
 	}
-	return b, nil
 }
 
 func formatKey(key []byte) string {
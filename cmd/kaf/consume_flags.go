@@ -0,0 +1,240 @@
+package main
+
+import (
+	"time"
+
+	prettyjson "github.com/hokaccha/go-prettyjson"
+	"github.com/spf13/cobra"
+)
+
+// consumeSettings are the values validateConsumeFlags parses from flags which
+// are not kept in package variables.
+type consumeSettings struct {
+	idPath                 jsonPath
+	hasSinceID, hasUntilID bool
+	splitExt               string
+	offsetMap              map[int32]offsetRange
+	last                   time.Duration
+}
+
+// validateConsumeFlags exits on invalid values and combinations of consume
+// flags and parses the flags which need parsing.
+func validateConsumeFlags(cmd *cobra.Command) consumeSettings {
+	var s consumeSettings
+	switch outputFlag {
+	case "default", "raw", "json":
+	case "csv":
+		var err error
+		csvColumns, err = parseCSVColumns(columnsFlag)
+		if err != nil {
+			errorExit("Invalid value for --columns: %v\n", err)
+		}
+		if raw || rawKeyFlag || flattenFlag {
+			errorExit("--output csv can not be combined with --raw, --raw-key or --flatten\n")
+		}
+	default:
+		errorExit("Invalid output format %v\n", outputFlag)
+	}
+	if raw {
+		outputFlag = "raw"
+	}
+	if rawKeyFlag {
+		if outputFlag == "json" && cmd.Flags().Changed("output") {
+			errorExit("--raw-key can not be combined with --output json\n")
+		}
+		outputFlag = "raw"
+	}
+	switch decodeErrorsFlag {
+	case "raw", "skip", "fail":
+	default:
+		errorExit("Invalid value for --decode-errors: %v\n", decodeErrorsFlag)
+	}
+
+	for _, f := range filterFlags {
+		filter, err := parseValueFilter(f)
+		if err != nil {
+			errorExit("Invalid value for --filter: %v\n", err)
+		}
+		valueFilters = append(valueFilters, filter)
+	}
+	for _, f := range headerFilterFlags {
+		filter, err := parseHeaderFilter(f)
+		if err != nil {
+			errorExit("Invalid value for --header-filter: %v\n", err)
+		}
+		headerFilters = append(headerFilters, filter)
+	}
+	if selectFlag != "" {
+		path, err := parseJSONPath(selectFlag)
+		if err != nil {
+			errorExit("Invalid value for --select: %v\n", err)
+		}
+		selectPath = path
+	}
+	hasSinceID, hasUntilID := cmd.Flags().Changed("since-id"), cmd.Flags().Changed("until-id")
+	s.hasSinceID, s.hasUntilID = hasSinceID, hasUntilID
+	if (hasSinceID || hasUntilID) && idFieldFlag == "" {
+		errorExit("--since-id and --until-id require --id-field\n")
+	}
+	if hasSinceID && hasUntilID && sinceIDFlag > untilIDFlag {
+		errorExit("--since-id must not be greater than --until-id\n")
+	}
+	if idFieldFlag != "" {
+		path, err := parseJSONPath(idFieldFlag)
+		if err != nil {
+			errorExit("Invalid value for --id-field: %v\n", err)
+		}
+		s.idPath = path
+	}
+	if printOffsetsOnlyFlag && (len(valueFilters) > 0 || selectPath != nil || s.idPath != nil) {
+		errorExit("--filter, --select and --id-field can not be combined with --print-offsets-only\n")
+	}
+	if topFlag < 0 {
+		errorExit("Invalid value for --top: %v\n", topFlag)
+	}
+	if topFlag > 0 {
+		if printOffsetsOnlyFlag {
+			errorExit("--top can not be combined with --print-offsets-only\n")
+		}
+		keyCounts = newKeyCounter(topMaxKeysFlag)
+	}
+
+	if flattenFlag && (outputFlag == "json" || printOffsetsOnlyFlag) {
+		errorExit("--flatten can not be combined with --output json or --print-offsets-only\n")
+	}
+	if compactJSONFlag && (outputFlag == "json" || outputFlag == "csv" || flattenFlag) {
+		errorExit("--compact-json can not be combined with --output json, --output csv or --flatten\n")
+	}
+	if histogramFlag {
+		if printOffsetsOnlyFlag || keyCounts != nil {
+			errorExit("--histogram can not be combined with --print-offsets-only or --top\n")
+		}
+		sizes = &sizeHistogram{}
+	}
+	if validateJSONFlag && (printOffsetsOnlyFlag || keyCounts != nil || sizes != nil || flattenFlag) {
+		errorExit("--validate-json can not be combined with --print-offsets-only, --top, --histogram or --flatten\n")
+	}
+	if splitByPartitionFlag != "" {
+		s.splitExt = splitOutputExt()
+	}
+
+	if dedupFlag {
+		if dedupByFlag != "key" && dedupByFlag != "value" {
+			errorExit("Invalid value for --dedup-by: %v\n", dedupByFlag)
+		}
+		if dedupSizeFlag < 1 {
+			errorExit("Invalid value for --dedup-size: %v\n", dedupSizeFlag)
+		}
+		if printOffsetsOnlyFlag {
+			errorExit("--dedup can not be combined with --print-offsets-only\n")
+		}
+		dedup = newDeduplicator(dedupWindowFlag, dedupSizeFlag)
+	}
+
+	if maxBytesTotalFlag != "" {
+		var err error
+		maxBytesTotal, err = parseByteSize(maxBytesTotalFlag)
+		if err != nil || maxBytesTotal == 0 {
+			errorExit("Invalid value for --max-bytes-total: %v\n", maxBytesTotalFlag)
+		}
+	}
+
+	if groupSnapshotFlag != "" {
+		if groupFlag != "" || follow || lastFlag != "" || sinceCommitFlag != "" || endOffsetFlag != "" || exitOnLastOffsetFlag || offsetMapFlag != "" || skipFlag > 0 {
+			errorExit("--group-snapshot can not be combined with --group, --follow, --last, --since-commit, --end-offset, --exit-on-last-offset-reached, --offset-map or --skip\n")
+		}
+	}
+
+	if reverseFlag {
+		if groupFlag != "" || follow || fair || orderByTime || lastFlag != "" || sinceCommitFlag != "" || endOffsetFlag != "" || exitOnLastOffsetFlag || offsetMapFlag != "" || groupSnapshotFlag != "" || stopOnEOFFlag {
+			errorExit("--reverse can not be combined with --group, --follow, --fair, --order-by-time, --last, --since-commit, --end-offset, --exit-on-last-offset-reached, --offset-map, --group-snapshot or --stop-on-eof\n")
+		}
+		if reverseWindowFlag < 1 {
+			errorExit("Invalid value for --reverse-window: %v\n", reverseWindowFlag)
+		}
+	}
+	if workersFlag < 0 {
+		errorExit("Invalid value for --workers: %v\n", workersFlag)
+	}
+	if workersFlag > 0 && (groupFlag != "" || offsetMapFlag != "" || groupSnapshotFlag != "" || reverseFlag) {
+		errorExit("--workers can not be combined with --group, --offset-map, --group-snapshot or --reverse\n")
+	}
+	if _, err := parseRebalanceStrategy(assignmentFlag); err != nil && workersFlag > 0 {
+		errorExit("Invalid value for --assignment: %v. Possible values: range, roundrobin\n", assignmentFlag)
+	}
+	if tailFlag < 0 {
+		errorExit("Invalid value for --tail: %v\n", tailFlag)
+	}
+	if tailFlag > 0 && (groupFlag != "" || lastFlag != "" || sinceCommitFlag != "" || offsetMapFlag != "" || groupSnapshotFlag != "" || reverseFlag) {
+		errorExit("--tail can not be combined with --group, --last, --since-commit, --offset-map, --group-snapshot or --reverse\n")
+	}
+	if stopOnEOFFlag && (groupFlag != "" || follow) {
+		errorExit("--stop-on-eof can not be combined with --group or --follow\n")
+	}
+
+	if err := parseTimeFlags(); err != nil {
+		errorExit("%v\n", err)
+	}
+
+	if offsetMapFlag != "" {
+		if groupFlag != "" || follow || lastFlag != "" || sinceCommitFlag != "" || endOffsetFlag != "" || exitOnLastOffsetFlag || cmd.Flags().Changed("offset") {
+			errorExit("--offset-map can not be combined with --group, --follow, --last, --since-commit, --end-offset, --exit-on-last-offset-reached or --offset\n")
+		}
+		var err error
+		s.offsetMap, err = readOffsetMap(offsetMapFlag)
+		if err != nil {
+			errorExit("Invalid --offset-map %v: %v\n", offsetMapFlag, err)
+		}
+	}
+
+	if lastFlag != "" {
+		if groupFlag != "" || follow || sinceCommitFlag != "" {
+			errorExit("--last can not be combined with --group, --follow or --since-commit\n")
+		}
+		var err error
+		s.last, err = parseDuration(lastFlag)
+		if err != nil || s.last <= 0 {
+			errorExit("Invalid value for --last: %v\n", lastFlag)
+		}
+	}
+
+	if sinceCommitFlag != "" {
+		if groupFlag != "" || follow {
+			errorExit("--since-commit can not be combined with --group or --follow\n")
+		}
+		// The reached offsets are committed, so every consumed message
+		// must have been printed. These flags stop consuming with
+		// messages consumed but not printed.
+		if limitFlag > 0 || maxBytesTotalFlag != "" || hasUntilID || orderByTime {
+			errorExit("--since-commit can not be combined with --limit, --max-bytes-total, --until-id or --order-by-time\n")
+		}
+		if endOffsetFlag != "" && endOffsetFlag != "newest-at-start" {
+			errorExit("--since-commit requires --end-offset newest-at-start\n")
+		}
+		endOffsetFlag = "newest-at-start"
+	} else if noCommitFlag {
+		errorExit("--no-commit requires --since-commit\n")
+	}
+	if exitOnLastOffsetFlag {
+		if endOffsetFlag != "" && endOffsetFlag != "newest-at-start" {
+			errorExit("--exit-on-last-offset-reached requires --end-offset newest-at-start\n")
+		}
+		endOffsetFlag = "newest-at-start"
+	}
+	switch endOffsetFlag {
+	case "":
+	case "newest-at-start":
+		if groupFlag != "" {
+			errorExit("--end-offset can not be combined with --group\n")
+		}
+	default:
+		errorExit("Invalid value for --end-offset: %v\n", endOffsetFlag)
+	}
+
+	for _, f := range []*prettyjson.Formatter{keyfmt, valuefmt} {
+		if err := applyTheme(f, themeFlag); err != nil {
+			errorExit("Invalid value for --theme: %v\n", err)
+		}
+	}
+	return s
+}
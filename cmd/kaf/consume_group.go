@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+var groupGenerationFlag bool
+
+func init() {
+	consumeCmd.Flags().BoolVar(&groupGenerationFlag, "group-generation", false, "Print the generation ID, member ID and assigned partitions of --group to stderr on each rebalance, and add group_generation and member_id to messages printed with --output json")
+}
+
+// groupSession identifies the generation of the group kaf is a member of.
+type groupSession struct {
+	generation int32
+	member     string
+}
+
+// currentGroupSession holds the groupSession messages are currently consumed
+// in, if --group-generation is set.
+var currentGroupSession atomic.Value
+
+// groupHandler handles the claims of a consumer group session. Messages are
+// marked after they have been handled, so they are committed with the next
+// commit interval or when the session ends.
+type groupHandler struct {
+	group string
+	emit  func(*sarama.ConsumerMessage)
+}
+
+func (h *groupHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	if !groupGenerationFlag {
+		return nil
+	}
+	currentGroupSession.Store(groupSession{generation: sess.GenerationID(), member: sess.MemberID()})
+
+	var assigned []string
+	for topic, partitions := range sess.Claims() {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		assigned = append(assigned, fmt.Sprintf("%v%v", topic, partitions))
+	}
+	fmt.Fprintf(os.Stderr, "Joined group %v in generation %v as member %v, assigned partitions: %v\n", h.group, sess.GenerationID(), sess.MemberID(), assigned)
+	return nil
+}
+
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *groupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			// Don't mark messages received after consuming was stopped,
+			// e.g. because --limit was reached.
+			select {
+			case <-stopConsume:
+				return nil
+			default:
+			}
+			h.emit(msg)
+			sess.MarkMessage(msg, "")
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// consumeGroup consumes topic as a member of group until consuming is
+// stopped. Stopping ends the session, which commits all marked offsets
+// before the group is left.
+func consumeGroup(client sarama.Client, group, topic string, emit func(*sarama.ConsumerMessage)) {
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(group, client)
+	if err != nil {
+		errorExit("Unable to create consumer group: %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopConsume
+		cancel()
+	}()
+
+	if client.Config().Consumer.Return.Errors {
+		go func() {
+			for err := range consumerGroup.Errors() {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	handler := &groupHandler{group: group, emit: emit}
+	for ctx.Err() == nil {
+		// Consume returns whenever the group rebalances and has to be
+		// called again to join the next generation.
+		if err := consumerGroup.Consume(ctx, []string{topic}, handler); err != nil {
+			errorExit("Unable to consume group: %v\n", err)
+		}
+	}
+
+	if err := consumerGroup.Close(); err != nil {
+		errorExit("Unable to leave consumer group: %v\n", err)
+	}
+}
+
+// parseRebalanceStrategy returns the balance strategy of the given name.
+func parseRebalanceStrategy(name string) (sarama.BalanceStrategy, error) {
+	switch name {
+	case "range":
+		return sarama.BalanceStrategyRange, nil
+	case "roundrobin":
+		return sarama.BalanceStrategyRoundRobin, nil
+	case "sticky", "cooperative-sticky":
+		return nil, fmt.Errorf("--rebalance-strategy %v is not supported by the Kafka client kaf is built with. Possible values: range, roundrobin", name)
+	default:
+		return nil, fmt.Errorf("Invalid value for --rebalance-strategy: %v. Possible values: range, roundrobin", name)
+	}
+}
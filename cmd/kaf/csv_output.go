@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	columnsFlag string
+	csvColumns  []string
+)
+
+func init() {
+	consumeCmd.Flags().StringVar(&columnsFlag, "columns", "partition,offset,timestamp,key,value", "Comma separated columns of --output csv. Possible values: partition, offset, timestamp, key, value")
+}
+
+// parseCSVColumns validates the columns given by --columns.
+func parseCSVColumns(s string) ([]string, error) {
+	columns := strings.Split(s, ",")
+	for _, column := range columns {
+		switch column {
+		case "partition", "offset", "timestamp", "key", "value":
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+	return columns, nil
+}
+
+// csvRow encodes a single CSV row, terminated by a newline.
+func csvRow(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// printCSVMessage prints msg as a CSV row with the columns of --columns. Keys
+// and values which are not valid UTF-8 are base64 encoded.
+func printCSVMessage(msg *sarama.ConsumerMessage, key, value []byte, stderr *bytes.Buffer) {
+	fields := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		switch column {
+		case "partition":
+			fields[i] = fmt.Sprint(msg.Partition)
+		case "offset":
+			fields[i] = fmt.Sprint(msg.Offset)
+		case "timestamp":
+			fields[i] = formatTimestamp(msg.Timestamp, time.RFC3339Nano)
+		case "key":
+			fields[i] = rawField(key)
+		case "value":
+			if msg.Value == nil {
+				fields[i] = nullMarkerFlag
+			} else {
+				fields[i] = rawField(value)
+			}
+		}
+	}
+
+	row, err := csvRow(fields)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not encode message as CSV: %v\n", err)
+	}
+	output.write(msg.Partition, stderr.Bytes(), row)
+}
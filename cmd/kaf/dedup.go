@@ -0,0 +1,77 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// deduplicator suppresses messages whose key or value was already printed
+// within a window. At most size entries are remembered, the least recently
+// printed ones are forgotten first.
+type deduplicator struct {
+	mu         sync.Mutex
+	window     time.Duration
+	size       int
+	entries    map[string]*list.Element
+	order      *list.List // of *dedupEntry, least recently printed first
+	suppressed int64
+}
+
+type dedupEntry struct {
+	id      string
+	printed time.Time
+}
+
+func newDeduplicator(window time.Duration, size int) *deduplicator {
+	return &deduplicator{
+		window:  window,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// dedupID returns the identity of data. Values are hashed to keep the
+// memory footprint independent of their size.
+func dedupID(data []byte, hash bool) string {
+	if !hash {
+		return string(data)
+	}
+	sum := sha256.Sum256(data)
+	return string(sum[:])
+}
+
+// seen returns true if id was printed less than the window before ts.
+// Otherwise id is recorded as printed at ts.
+func (d *deduplicator) seen(id string, ts time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.entries[id]; ok {
+		entry := elem.Value.(*dedupEntry)
+		if ts.Sub(entry.printed) < d.window {
+			d.suppressed++
+			return true
+		}
+		entry.printed = ts
+		d.order.MoveToBack(elem)
+		return false
+	}
+
+	d.entries[id] = d.order.PushBack(&dedupEntry{id: id, printed: ts})
+	for d.order.Len() > d.size {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).id)
+	}
+	return false
+}
+
+// suppressedCount returns the number of messages suppressed so far.
+func (d *deduplicator) suppressedCount() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.suppressed
+}
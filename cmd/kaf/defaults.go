@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/fatih/color"
+)
+
+// applyConfigDefaults sets flags which were not given on the command line to
+// the defaults of the config file.
+func applyConfigDefaults() {
+	defaults := config.Defaults
+	if defaults == nil {
+		return
+	}
+
+	flags := consumeCmd.Flags()
+	if defaults.Consume.Offset != "" && !flags.Changed("offset") {
+		offsetFlag = defaults.Consume.Offset
+	}
+	if defaults.Consume.Output != "" && !flags.Changed("output") {
+		outputFlag = defaults.Consume.Output
+	}
+
+	if defaults.Color != nil && !*defaults.Color {
+		color.NoColor = true
+		if !flags.Changed("theme") {
+			themeFlag = "mono"
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// drainIncompleteExitCode is the exit code of consume
+// --exit-on-last-offset-reached if consuming stopped before all partitions
+// reached their end offset.
+const drainIncompleteExitCode = 3
+
+// drainProgress tracks the next offset to consume of each partition.
+type drainProgress struct {
+	mu   sync.Mutex
+	next map[int32]int64
+}
+
+func newDrainProgress() *drainProgress {
+	return &drainProgress{next: make(map[int32]int64)}
+}
+
+func (p *drainProgress) set(partition int32, next int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next[partition] = next
+}
+
+// offsets returns the next offset to consume of each partition.
+func (p *drainProgress) offsets() map[int32]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offsets := make(map[int32]int64, len(p.next))
+	for partition, next := range p.next {
+		offsets[partition] = next
+	}
+	return offsets
+}
+
+// report prints the progress of each partition relative to its end offset to
+// stderr and returns true if all partitions reached their end offset.
+func (p *drainProgress) report(end map[int32]int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	partitions := make([]int32, 0, len(end))
+	for partition := range end {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	complete := true
+	for _, partition := range partitions {
+		next, ok := p.next[partition]
+		if !ok || next < end[partition] {
+			complete = false
+		}
+		fmt.Fprintf(os.Stderr, "Partition %v reached offset %v/%v\n", partition, next, end[partition])
+	}
+	return complete
+}
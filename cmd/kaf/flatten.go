@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// flattenJSON formats every leaf of a JSON document as path=value, one per
+// line, e.g. {"a":{"b":[1]}} becomes a.b[0]=1. Object keys are sorted. It
+// returns false if data is not JSON.
+func flattenJSON(data []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	var lines []string
+	flattenValue("", v, &lines)
+	return []byte(strings.Join(lines, "\n")), true
+}
+
+func flattenValue(path string, v interface{}, lines *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			*lines = append(*lines, flattenedLeaf(path, "{}"))
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			flattenValue(child, v[key], lines)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			*lines = append(*lines, flattenedLeaf(path, "[]"))
+			return
+		}
+		for i, elem := range v {
+			flattenValue(fmt.Sprintf("%v[%v]", path, i), elem, lines)
+		}
+	default:
+		*lines = append(*lines, flattenedLeaf(path, formatJSONValue(v)))
+	}
+}
+
+// flattenedLeaf formats a leaf. Documents which are a single scalar have no
+// path, so only the value is printed.
+func flattenedLeaf(path, value string) string {
+	if path == "" {
+		return value
+	}
+	return path + "=" + value
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sort"
 	"unicode"
 
@@ -29,8 +28,14 @@ func init() {
 	groupCmd.AddCommand(groupDeleteCmd)
 
 	groupLsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	addLayoutFlags(groupLsCmd)
+	groupLsCmd.Flags().BoolVar(&pagerFlag, "pager", false, "Page output through $KAF_PAGER, $PAGER or less if stdout is a terminal")
+	groupDescribeCmd.Flags().BoolVar(&pagerFlag, "pager", false, "Page output through $KAF_PAGER, $PAGER or less if stdout is a terminal")
+	groupDescribeCmd.Flags().StringVarP(&groupTopicFlag, "topic", "t", "", "Only show offsets, lag and members assigned to this topic")
 }
 
+var groupTopicFlag string
+
 const (
 	tabwriterMinWidth       = 6
 	tabwriterMinWidthNested = 2
@@ -64,7 +69,7 @@ var groupDeleteCmd = &cobra.Command{
 		if err != nil {
 			errorExit("Could not delete consumer group %v: %v\n", group, err.Error())
 		} else {
-			fmt.Printf("Deleted consumer group %v.\n", group)
+			printInfo("Deleted consumer group %v.\n", group)
 		}
 
 	},
@@ -75,6 +80,7 @@ var groupLsCmd = &cobra.Command{
 	Short: "List groups",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		checkLayoutFlags()
 		admin := getClusterAdmin()
 
 		groups, err := admin.ListConsumerGroups()
@@ -91,10 +97,20 @@ var groupLsCmd = &cobra.Command{
 			return groupList[i] < groupList[j]
 		})
 
-		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		out, done := startPager()
+		defer done()
+
+		w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 
 		if !noHeaderFlag {
-			fmt.Fprintf(w, "NAME\tSTATE\tCONSUMERS\t\n")
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "NAME\t\n")
+			case wideFlag:
+				fmt.Fprintf(w, "NAME\tSTATE\tCONSUMERS\tPROTOCOL TYPE\tPROTOCOL\t\n")
+			default:
+				fmt.Fprintf(w, "NAME\tSTATE\tCONSUMERS\t\n")
+			}
 		}
 
 		groupDescs, err := admin.DescribeConsumerGroups(groupList)
@@ -105,13 +121,20 @@ var groupLsCmd = &cobra.Command{
 		for _, detail := range groupDescs {
 			state := detail.State
 			consumers := len(detail.Members)
-			fmt.Fprintf(w, "%v\t%v\t%v\t\n", detail.GroupId, state, consumers)
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "%v\t\n", detail.GroupId)
+			case wideFlag:
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t\n", detail.GroupId, state, consumers, detail.ProtocolType, detail.Protocol)
+			default:
+				fmt.Fprintf(w, "%v\t%v\t%v\t\n", detail.GroupId, state, consumers)
+			}
 		}
 
 		if len(groupDescs) != 0 {
 			w.Flush()
 		} else {
-			fmt.Printf("No Groups found\n")
+			fmt.Fprintf(out, "No Groups found\n")
 		}
 
 		return
@@ -119,10 +142,22 @@ var groupLsCmd = &cobra.Command{
 }
 
 var groupDescribeCmd = &cobra.Command{
-	Use:   "describe",
+	Use:   "describe [GROUP]",
 	Short: "Describe consumer group",
-	Args:  cobra.ExactArgs(1),
+	Long:  "Describe a consumer group. With --all, the state and total lag of every group of the cluster is printed instead.",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if allGroupsFlag {
+			if len(args) > 0 {
+				errorExit("Either give a group or --all, not both\n")
+			}
+			describeAllGroups()
+			return
+		}
+		if len(args) == 0 {
+			errorExit("A group is required, or --all to describe all groups\n")
+		}
+
 		// TODO List: This API can be used to find the current groups managed by a broker. To get a list of all groups in the cluster, you must send ListGroup to all brokers.
 		// same goes probably for topics
 		admin := getClusterAdmin()
@@ -158,8 +193,16 @@ var groupDescribeCmd = &cobra.Command{
 		for topic := range topicsDedup {
 			topics = append(topics, topic)
 		}
+		if groupTopicFlag != "" {
+			// The group may have committed offsets for the topic even if
+			// no member is currently assigned to it.
+			topics = []string{groupTopicFlag}
+		}
+
+		out, done := startPager()
+		defer done()
 
-		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 		fmt.Fprintf(w, "Group ID:\t%v\n", group.GroupId)
 		fmt.Fprintf(w, "State:\t%v\n", group.State)
 		fmt.Fprintf(w, "Protocol:\t%v\n", group.Protocol)
@@ -168,7 +211,7 @@ var groupDescribeCmd = &cobra.Command{
 		fmt.Fprintf(w, "Offsets:\t\n")
 
 		w.Flush()
-		w.Init(os.Stdout, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
+		w.Init(out, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
 
 		if len(topics) > 0 {
 			topicMeta, _ := admin.DescribeTopics(topics)
@@ -210,14 +253,24 @@ var groupDescribeCmd = &cobra.Command{
 		fmt.Fprintf(w, "Members:\t")
 
 		w.Flush()
-		w.Init(os.Stdout, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
+		w.Init(out, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
 
 		fmt.Fprintln(w)
 		for _, member := range group.Members {
+			assignment, err := member.GetMemberAssignment()
+			if groupTopicFlag != "" {
+				// Only members assigned to the topic are of interest.
+				if err != nil {
+					continue
+				}
+				if _, ok := assignment.Topics[groupTopicFlag]; !ok {
+					continue
+				}
+			}
+
 			fmt.Fprintf(w, "\t%v:\n", member.ClientId)
 			fmt.Fprintf(w, "\t\tHost:\t%v\n", member.ClientHost)
 
-			assignment, err := member.GetMemberAssignment()
 			if err != nil {
 				continue
 			}
@@ -228,6 +281,9 @@ var groupDescribeCmd = &cobra.Command{
 			fmt.Fprintf(w, "\t\t  -----\t----------\t")
 
 			for topic, partitions := range assignment.Topics {
+				if groupTopicFlag != "" && topic != groupTopicFlag {
+					continue
+				}
 				fmt.Fprintf(w, "\n\t\t  %v\t%v\t", topic, partitions)
 			}
 
@@ -261,12 +317,32 @@ var groupDescribeCmd = &cobra.Command{
 }
 
 func getHighWatermarks(topic string, partitions []int32) (watermarks map[int32]int64) {
-	watermarks = make(map[int32]int64)
-	client := getClient()
+	return getHighWatermarksFromClient(getClient(), topic, partitions)
+}
+
+// getHighWatermarksFromClient fetches the high watermarks of the given
+// partitions, issuing a single offset request per leader broker.
+func getHighWatermarksFromClient(client sarama.Client, topic string, partitions []int32) (watermarks map[int32]int64) {
+	return getOffsetsFromClient(client, topic, partitions, sarama.OffsetNewest)
+}
+
+// getOldestOffsetsFromClient fetches the oldest available offsets of the
+// given partitions, issuing a single offset request per leader broker.
+func getOldestOffsetsFromClient(client sarama.Client, topic string, partitions []int32) (offsets map[int32]int64) {
+	return getOffsetsFromClient(client, topic, partitions, sarama.OffsetOldest)
+}
+
+// getOffsetsFromClient resolves the offsets of the given partitions at time,
+// which is either a timestamp in milliseconds, sarama.OffsetNewest or
+// sarama.OffsetOldest.
+func getOffsetsFromClient(client sarama.Client, topic string, partitions []int32, time int64) (watermarks map[int32]int64) {
 	leaders := make(map[*sarama.Broker][]int32)
 
 	for _, partition := range partitions {
-		leader, _ := client.Leader(topic, partition)
+		leader, err := client.Leader(topic, partition)
+		if err != nil {
+			errorExit("Unable to get leader: %v\n", err)
+		}
 		leaders[leader] = append(leaders[leader], partition)
 	}
 	wg := sync.WaitGroup{}
@@ -280,25 +356,33 @@ func getHighWatermarks(topic string, partitions []int32) (watermarks map[int32]i
 		}
 
 		for _, partition := range partitions {
-			req.AddBlock(topic, partition, int64(-1), int32(0))
+			req.AddBlock(topic, partition, time, int32(0))
 		}
 
 		// Query distinct brokers in parallel
-		go func(leader *sarama.Broker, req *sarama.OffsetRequest) {
-			resp, err := leader.GetAvailableOffsets(req)
+		go func(leader *sarama.Broker, req *sarama.OffsetRequest, partitions []int32) {
+			resp, err := getAvailableOffsetsRetry(leader, req, offsetsRetry)
 			if err != nil {
 				errorExit("Unable to get available offsets: %v\n", err)
 			}
 
 			watermarksFromLeader := make(map[int32]int64)
-			for partition, block := range resp.Blocks[topic] {
+			for _, partition := range partitions {
+				// An offset of -1 is only meaningful without error.
+				block := resp.GetBlock(topic, partition)
+				if block == nil {
+					errorExit("Unable to get offset of partition %v: no response\n", partition)
+				}
+				if block.Err != sarama.ErrNoError {
+					errorExit("Unable to get offset of partition %v: %v\n", partition, block.Err)
+				}
 				watermarksFromLeader[partition] = block.Offset
 			}
 
 			results <- watermarksFromLeader
 			wg.Done()
 
-		}(leader, req)
+		}(leader, req, partitions)
 
 	}
 
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	allGroupsFlag   bool
+	concurrencyFlag int
+	groupOutputFlag string
+)
+
+func init() {
+	groupDescribeCmd.Flags().BoolVar(&allGroupsFlag, "all", false, "Describe all groups of the cluster and print their total lag")
+	groupDescribeCmd.Flags().IntVar(&concurrencyFlag, "concurrency", 8, "Number of groups described in parallel with --all")
+	groupDescribeCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers of --all")
+	groupDescribeCmd.Flags().StringVarP(&groupOutputFlag, "output", "o", "default", "Output format of --all. Possible values: default, json. json prints one JSON object per group.")
+}
+
+// groupLag is the summary of a group printed by group describe --all.
+type groupLag struct {
+	Group    string   `json:"group"`
+	State    string   `json:"state"`
+	Members  int      `json:"members"`
+	Topics   []string `json:"topics"`
+	TotalLag int64    `json:"total_lag"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// cachedWatermarks holds the high watermarks of a topic. done is closed once
+// they are fetched.
+type cachedWatermarks struct {
+	done       chan struct{}
+	watermarks map[int32]int64
+	err        error
+}
+
+// watermarkCache fetches the high watermarks of each topic only once, even if
+// it is consumed by many groups.
+type watermarkCache struct {
+	client sarama.Client
+
+	mu     sync.Mutex
+	topics map[string]*cachedWatermarks
+}
+
+func newWatermarkCache(client sarama.Client) *watermarkCache {
+	return &watermarkCache{client: client, topics: make(map[string]*cachedWatermarks)}
+}
+
+func (c *watermarkCache) get(topic string) (map[int32]int64, error) {
+	c.mu.Lock()
+	cw, ok := c.topics[topic]
+	if ok {
+		c.mu.Unlock()
+		<-cw.done
+		return cw.watermarks, cw.err
+	}
+	cw = &cachedWatermarks{done: make(chan struct{})}
+	c.topics[topic] = cw
+	c.mu.Unlock()
+
+	partitions, err := c.client.Partitions(topic)
+	if err != nil {
+		cw.err = err
+	} else {
+		cw.watermarks = getHighWatermarksFromClient(c.client, topic, partitions)
+	}
+	close(cw.done)
+	return cw.watermarks, cw.err
+}
+
+// describeAllGroups prints the state and total lag of every group of the
+// cluster.
+func describeAllGroups() {
+	switch groupOutputFlag {
+	case "default", "json":
+	default:
+		errorExit("Invalid output format %v\n", groupOutputFlag)
+	}
+	if concurrencyFlag < 1 {
+		errorExit("--concurrency must be at least 1\n")
+	}
+
+	admin := getClusterAdmin()
+	client := getClient()
+
+	groupIDs, err := admin.ListConsumerGroups()
+	if err != nil {
+		errorExit("Unable to list consumer groups: %v\n", err)
+	}
+	ids := make([]string, 0, len(groupIDs))
+	for id := range groupIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	descriptions := make(map[string]*sarama.GroupDescription, len(ids))
+	if len(ids) > 0 {
+		groups, err := admin.DescribeConsumerGroups(ids)
+		if err != nil {
+			errorExit("Unable to describe consumer groups: %v\n", err)
+		}
+		for _, group := range groups {
+			descriptions[group.GroupId] = group
+		}
+	}
+
+	cache := newWatermarkCache(client)
+	results := make([]groupLag, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrencyFlag; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = describeGroupLag(admin, cache, ids[j], descriptions[ids[j]])
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	out, done := startPager()
+	defer done()
+
+	if groupOutputFlag == "json" {
+		for _, result := range results {
+			b, err := json.Marshal(result)
+			if err != nil {
+				errorExit("Unable to marshal group: %v\n", err)
+			}
+			fmt.Fprintln(out, string(b))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(w, "GROUP\tSTATE\tMEMBERS\tTOPICS\tTOTAL LAG\t\n")
+	}
+	for _, result := range results {
+		lag := fmt.Sprint(result.TotalLag)
+		if result.Error != "" {
+			lag = "error: " + result.Error
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t\n", result.Group, result.State, result.Members, len(result.Topics), lag)
+	}
+	w.Flush()
+}
+
+// describeGroupLag sums up the lag of all partitions the group committed
+// offsets for. With --topic, only that topic is taken into account.
+func describeGroupLag(admin sarama.ClusterAdmin, cache *watermarkCache, id string, desc *sarama.GroupDescription) groupLag {
+	result := groupLag{Group: id, Topics: []string{}}
+	if desc != nil {
+		result.State = desc.State
+		result.Members = len(desc.Members)
+	}
+
+	// Passing no partitions fetches the offsets of all topics, which
+	// requires Kafka 0.10.2 or later.
+	offsets, err := admin.ListConsumerGroupOffsets(id, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	for topic, blocks := range offsets.Blocks {
+		if groupTopicFlag != "" && topic != groupTopicFlag {
+			continue
+		}
+		watermarks, err := cache.get(topic)
+		if err != nil {
+			result.Error = fmt.Sprintf("topic %v: %v", topic, err)
+			return result
+		}
+		result.Topics = append(result.Topics, topic)
+		for partition, block := range blocks {
+			if block.Err != sarama.ErrNoError || block.Offset < 0 {
+				continue
+			}
+			if lag := watermarks[partition] - block.Offset; lag > 0 {
+				result.TotalLag += lag
+			}
+		}
+	}
+	sort.Strings(result.Topics)
+	return result
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchFlag         bool
+	watchIntervalFlag time.Duration
+)
+
+func init() {
+	groupCmd.AddCommand(groupMembersCmd)
+
+	groupMembersCmd.Flags().BoolVarP(&watchFlag, "watch", "w", false, "Poll the group and print a line whenever its state, membership or assignments change")
+	groupMembersCmd.Flags().DurationVar(&watchIntervalFlag, "interval", 2*time.Second, "Poll interval of --watch")
+	groupMembersCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+}
+
+// memberSnapshot is the state of a single group member at one point in time.
+type memberSnapshot struct {
+	clientID   string
+	host       string
+	assignment string
+}
+
+// groupSnapshot is the state and membership of a group at one point in time.
+type groupSnapshot struct {
+	state   string
+	members map[string]memberSnapshot
+}
+
+func describeGroupMembers(admin sarama.ClusterAdmin, group string) groupSnapshot {
+	groups, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		errorExit("Unable to describe consumer groups: %v\n", err)
+	}
+	if len(groups) == 0 {
+		errorExit("Did not receive expected describe consumergroup result\n")
+	}
+
+	snapshot := groupSnapshot{
+		state:   groups[0].State,
+		members: make(map[string]memberSnapshot, len(groups[0].Members)),
+	}
+	for id, member := range groups[0].Members {
+		m := memberSnapshot{
+			clientID: member.ClientId,
+			host:     member.ClientHost,
+		}
+		if assignment, err := member.GetMemberAssignment(); err == nil {
+			m.assignment = formatAssignment(assignment.Topics)
+		}
+		snapshot.members[id] = m
+	}
+	return snapshot
+}
+
+// formatAssignment formats the assigned partitions of a member, sorted by
+// topic.
+func formatAssignment(topics map[string][]int32) string {
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		names = append(names, topic)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, topic := range names {
+		partitions := topics[topic]
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		parts = append(parts, fmt.Sprintf("%v:%v", topic, partitions))
+	}
+	return strings.Join(parts, " ")
+}
+
+func sortedMemberIDs(members map[string]memberSnapshot) []string {
+	ids := make([]string, 0, len(members))
+	for id := range members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+var groupMembersCmd = &cobra.Command{
+	Use:   "members GROUP",
+	Short: "Display members of a consumer group",
+	Long:  "Display members of a consumer group. With --watch, the group is polled and every change of its state, membership or assignments is printed.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+		snapshot := describeGroupMembers(admin, args[0])
+
+		if !watchFlag {
+			w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+			fmt.Fprintf(w, "State:\t%v\t\n", snapshot.state)
+			if !noHeaderFlag {
+				fmt.Fprintf(w, "MEMBER ID\tCLIENT ID\tHOST\tASSIGNMENT\t\n")
+			}
+			for _, id := range sortedMemberIDs(snapshot.members) {
+				m := snapshot.members[id]
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", id, m.clientID, m.host, m.assignment)
+			}
+			w.Flush()
+			return
+		}
+
+		printMemberChanges(groupSnapshot{members: map[string]memberSnapshot{}}, snapshot)
+		for range time.Tick(watchIntervalFlag) {
+			next := describeGroupMembers(admin, args[0])
+			printMemberChanges(snapshot, next)
+			snapshot = next
+		}
+	},
+}
+
+// printMemberChanges prints one line for each difference between two
+// snapshots of a group.
+func printMemberChanges(prev, next groupSnapshot) {
+	ts := time.Now().Format(time.RFC3339)
+
+	if prev.state != next.state {
+		if prev.state == "" {
+			fmt.Printf("%v\tstate\t%v\n", ts, next.state)
+		} else {
+			fmt.Printf("%v\tstate\t%v -> %v\n", ts, prev.state, next.state)
+		}
+	}
+
+	for _, id := range sortedMemberIDs(prev.members) {
+		if _, ok := next.members[id]; !ok {
+			m := prev.members[id]
+			fmt.Printf("%v\tleft\t%v\t%v\t%v\n", ts, id, m.clientID, m.host)
+		}
+	}
+
+	for _, id := range sortedMemberIDs(next.members) {
+		m := next.members[id]
+		old, ok := prev.members[id]
+		switch {
+		case !ok:
+			fmt.Printf("%v\tjoined\t%v\t%v\t%v\t%v\n", ts, id, m.clientID, m.host, m.assignment)
+		case old.assignment != m.assignment:
+			fmt.Printf("%v\tassigned\t%v\t%v\t%v\t%v\n", ts, id, m.clientID, m.host, m.assignment)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+var offsetsFileFlag string
+
+func init() {
+	groupCmd.AddCommand(groupOffsetsCmd)
+	groupOffsetsCmd.AddCommand(groupOffsetsExportCmd)
+	groupOffsetsCmd.AddCommand(groupOffsetsImportCmd)
+	groupOffsetsCmd.AddCommand(groupOffsetsSetToLagCmd)
+
+	groupOffsetsImportCmd.Flags().StringVarP(&offsetsFileFlag, "file", "f", "", "File with offsets as written by group offsets export")
+	groupOffsetsImportCmd.Flags().BoolVar(&yesFlag, "yes", false, "Confirm overwriting the committed offsets of the group")
+
+	groupOffsetsSetToLagCmd.Flags().StringVarP(&groupTopicFlag, "topic", "t", "", "Only set the offsets of this topic (default all topics the group committed offsets for)")
+	groupOffsetsSetToLagCmd.Flags().BoolVar(&yesFlag, "yes", false, "Confirm overwriting the committed offsets of the group")
+}
+
+// fetchGroupOffsets fetches the committed offsets of group for the given
+// partitions of topic. Partitions without a committed offset are missing from
+// the result.
+func fetchGroupOffsets(client sarama.Client, group, topic string, partitions []int32) (map[int32]int64, error) {
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &sarama.OffsetFetchRequest{ConsumerGroup: group, Version: 1}
+	for _, partition := range partitions {
+		req.AddPartition(topic, partition)
+	}
+	resp, err := coordinator.FetchOffset(req)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		block := resp.GetBlock(topic, partition)
+		if block == nil {
+			continue
+		}
+		if block.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("partition %v: %v", partition, block.Err)
+		}
+		if block.Offset >= 0 {
+			offsets[partition] = block.Offset
+		}
+	}
+	return offsets, nil
+}
+
+// commitGroupOffsets commits offsets of group for partitions of topic outside
+// of a group generation. The coordinator rejects the commit if the group has
+// active members.
+func commitGroupOffsets(client sarama.Client, group, topic string, offsets map[int32]int64) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return err
+	}
+
+	req := &sarama.OffsetCommitRequest{
+		Version:                 1,
+		ConsumerGroup:           group,
+		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
+	}
+	for partition, offset := range offsets {
+		req.AddBlock(topic, partition, offset, sarama.ReceiveTime, "")
+	}
+	resp, err := coordinator.CommitOffset(req)
+	if err != nil {
+		return err
+	}
+
+	for partition, kerr := range resp.Errors[topic] {
+		if kerr != sarama.ErrNoError {
+			return fmt.Errorf("partition %v: %v", partition, kerr)
+		}
+	}
+	return nil
+}
+
+// groupOffsetsSnapshot holds the committed offsets of a group, keyed by topic
+// and partition.
+type groupOffsetsSnapshot struct {
+	Group  string                     `json:"group"`
+	Topics map[string]map[int32]int64 `json:"topics"`
+}
+
+var groupOffsetsCmd = &cobra.Command{
+	Use:   "offsets",
+	Short: "Export, import and reset committed offsets of a group",
+}
+
+var groupOffsetsExportCmd = &cobra.Command{
+	Use:   "export GROUP",
+	Short: "Print the committed offsets of a group as JSON",
+	Long:  "Print the committed offsets of all topics of a group as JSON, suitable for group offsets import. Requires Kafka 0.10.2 or later.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+
+		// Passing no partitions fetches the offsets of all topics.
+		resp, err := admin.ListConsumerGroupOffsets(args[0], nil)
+		if err != nil {
+			errorExit("Unable to list offsets of group %v: %v\n", args[0], err)
+		}
+		if resp.Err != sarama.ErrNoError {
+			errorExit("Unable to list offsets of group %v: %v\n", args[0], resp.Err)
+		}
+
+		snapshot := groupOffsetsSnapshot{Group: args[0], Topics: make(map[string]map[int32]int64)}
+		for topic, blocks := range resp.Blocks {
+			for partition, block := range blocks {
+				if block.Err != sarama.ErrNoError {
+					errorExit("Unable to list offset of topic %v partition %v: %v\n", topic, partition, block.Err)
+				}
+				if block.Offset < 0 {
+					continue
+				}
+				if snapshot.Topics[topic] == nil {
+					snapshot.Topics[topic] = make(map[int32]int64)
+				}
+				snapshot.Topics[topic][partition] = block.Offset
+			}
+		}
+
+		out, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			errorExit("Unable to encode offsets: %v\n", err)
+		}
+		fmt.Println(string(out))
+	},
+}
+
+var groupOffsetsImportCmd = &cobra.Command{
+	Use:   "import GROUP",
+	Short: "Commit offsets exported with group offsets export",
+	Long:  "Commit offsets exported with group offsets export for a group, e.g. to roll back or to migrate the group to another cluster. Partitions missing in the cluster are skipped with a warning. The group must not have active members.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := args[0]
+		if offsetsFileFlag == "" {
+			errorExit("The --file flag is required\n")
+		}
+		data, err := ioutil.ReadFile(offsetsFileFlag)
+		if err != nil {
+			errorExit("Unable to read offsets: %v\n", err)
+		}
+		var snapshot groupOffsetsSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			errorExit("Unable to parse offsets: %v\n", err)
+		}
+		if snapshot.Group != "" && snapshot.Group != group {
+			fmt.Fprintf(os.Stderr, "Warning: offsets were exported from group %v\n", snapshot.Group)
+		}
+		if !yesFlag {
+			errorExit("Importing offsets overwrites the committed offsets of group %v, confirm with --yes\n", group)
+		}
+
+		client := getClient()
+
+		topics := make([]string, 0, len(snapshot.Topics))
+		for topic := range snapshot.Topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "TOPIC\tPARTITION\tOFFSET\t\n")
+		for _, topic := range topics {
+			partitions, err := client.Partitions(topic)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping topic %v: %v\n", topic, err)
+				continue
+			}
+			highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+			offsets := make(map[int32]int64)
+			for partition, offset := range snapshot.Topics[topic] {
+				wm, ok := highWatermarks[partition]
+				if !ok {
+					fmt.Fprintf(os.Stderr, "Warning: skipping topic %v partition %v, which does not exist\n", topic, partition)
+					continue
+				}
+				if offset > wm {
+					fmt.Fprintf(os.Stderr, "Warning: offset %v of topic %v partition %v is beyond its high watermark %v\n", offset, topic, partition, wm)
+				}
+				offsets[partition] = offset
+			}
+			if err := commitGroupOffsets(client, group, topic, offsets); err != nil {
+				errorExit("Unable to commit offsets of topic %v: %v\n", topic, err)
+			}
+
+			committed := make([]int32, 0, len(offsets))
+			for partition := range offsets {
+				committed = append(committed, partition)
+			}
+			sort.Slice(committed, func(i, j int) bool { return committed[i] < committed[j] })
+			for _, partition := range committed {
+				fmt.Fprintf(w, "%v\t%v\t%v\t\n", topic, partition, offsets[partition])
+			}
+		}
+		w.Flush()
+	},
+}
+
+var groupOffsetsSetToLagCmd = &cobra.Command{
+	Use:   "set-to-lag GROUP N",
+	Short: "Commit offsets so that the group only reads the last N messages of each partition",
+	Long:  "Commit offsets so that the group only reads the last N messages of each partition, i.e. the high watermark minus N, but not before the oldest offset. The group must not have active members.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := args[0]
+		lag, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || lag < 0 {
+			errorExit("Invalid number of messages %v\n", args[1])
+		}
+		if !yesFlag {
+			errorExit("Setting offsets overwrites the committed offsets of group %v, confirm with --yes\n", group)
+		}
+
+		admin := getClusterAdmin()
+		requireInactiveGroup(admin, group)
+
+		topics := []string{groupTopicFlag}
+		if groupTopicFlag == "" {
+			// Passing no partitions fetches the offsets of all topics.
+			resp, err := admin.ListConsumerGroupOffsets(group, nil)
+			if err != nil {
+				errorExit("Unable to list offsets of group %v: %v\n", group, err)
+			}
+			topics = topics[:0]
+			for topic := range resp.Blocks {
+				topics = append(topics, topic)
+			}
+			if len(topics) == 0 {
+				errorExit("Group %v has no committed offsets, select a topic with --topic\n", group)
+			}
+			sort.Strings(topics)
+		}
+
+		client := getClient()
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "TOPIC\tPARTITION\tOFFSET\tLAG\t\n")
+		for _, topic := range topics {
+			partitions, err := client.Partitions(topic)
+			if err != nil {
+				errorExit("Unable to get partitions of topic %v: %v\n", topic, err)
+			}
+			sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+			oldest := getOldestOffsetsFromClient(client, topic, partitions)
+			highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+			offsets := make(map[int32]int64, len(partitions))
+			for _, partition := range partitions {
+				offset := highWatermarks[partition] - lag
+				if offset < oldest[partition] {
+					offset = oldest[partition]
+				}
+				offsets[partition] = offset
+			}
+			if err := commitGroupOffsets(client, group, topic, offsets); err != nil {
+				errorExit("Unable to commit offsets of topic %v: %v\n", topic, err)
+			}
+			for _, partition := range partitions {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", topic, partition, offsets[partition], highWatermarks[partition]-offsets[partition])
+			}
+		}
+		w.Flush()
+	},
+}
+
+// requireInactiveGroup exits if group has active members, whose commits
+// would overwrite offsets committed by kaf.
+func requireInactiveGroup(admin sarama.ClusterAdmin, group string) {
+	groups, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		errorExit("Unable to describe consumer group: %v\n", err)
+	}
+	if len(groups) > 0 && len(groups[0].Members) > 0 {
+		errorExit("Group %v has active members, stop its consumers first\n", group)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+var seedToFlag string
+
+func init() {
+	groupCmd.AddCommand(groupSeedCmd)
+
+	groupSeedCmd.Flags().StringVarP(&groupTopicFlag, "topic", "t", "", "Topic to commit offsets for")
+	groupSeedCmd.Flags().StringVar(&seedToFlag, "to", "oldest", "Where the group starts. Possible values: oldest, newest, an offset, or a time in RFC3339 format (e.g. 2019-07-01T12:00:00Z)")
+	groupSeedCmd.Flags().BoolVar(&yesFlag, "yes", false, "Confirm committing offsets for the group")
+}
+
+var groupSeedCmd = &cobra.Command{
+	Use:   "seed GROUP",
+	Short: "Commit start offsets for a group which has not consumed a topic yet",
+	Long:  "Commit offsets for all partitions of a topic for a group which has no committed offsets for the topic yet, so that its consumers start at the given position instead of applying their offset reset policy.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group := args[0]
+		if groupTopicFlag == "" {
+			errorExit("The --topic flag is required\n")
+		}
+		topic := groupTopicFlag
+		if !yesFlag {
+			errorExit("Seeding commits offsets for group %v, confirm with --yes\n", group)
+		}
+
+		requireInactiveGroup(getClusterAdmin(), group)
+
+		client := getClient()
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			errorExit("Unable to get partitions: %v\n", err)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		committed, err := fetchGroupOffsets(client, group, topic, partitions)
+		if err != nil {
+			errorExit("Unable to fetch offsets of group %v: %v\n", group, err)
+		}
+		if len(committed) > 0 {
+			errorExit("Group %v already committed offsets for topic %v\n", group, topic)
+		}
+
+		offsets := seedOffsets(client, topic, partitions)
+		if err := commitGroupOffsets(client, group, topic, offsets); err != nil {
+			errorExit("Unable to commit offsets: %v\n", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "PARTITION\tOFFSET\t\n")
+		for _, partition := range partitions {
+			fmt.Fprintf(w, "%v\t%v\t\n", partition, offsets[partition])
+		}
+		w.Flush()
+	},
+}
+
+// seedOffsets resolves --to into an offset per partition.
+func seedOffsets(client sarama.Client, topic string, partitions []int32) map[int32]int64 {
+	switch seedToFlag {
+	case "oldest":
+		return getOldestOffsetsFromClient(client, topic, partitions)
+	case "newest":
+		return getHighWatermarksFromClient(client, topic, partitions)
+	}
+
+	if offset, err := strconv.ParseInt(seedToFlag, 10, 64); err == nil {
+		if offset < 0 {
+			errorExit("Invalid value for --to: %v\n", seedToFlag)
+		}
+		offsets := make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			offsets[partition] = offset
+		}
+		return offsets
+	}
+
+	t, err := parseTimestamp(seedToFlag)
+	if err != nil {
+		errorExit("Invalid value for --to: %v\n", seedToFlag)
+	}
+	offsets := getOffsetsFromClient(client, topic, partitions, timeToMillis(t))
+	highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+	for partition, offset := range offsets {
+		if offset < 0 {
+			// No message was produced after t.
+			offsets[partition] = highWatermarks[partition]
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Shopify/sarama"
+)
+
+var groupSnapshotFlag string
+
+func init() {
+	consumeCmd.Flags().StringVar(&groupSnapshotFlag, "group-snapshot", "", "Print the next message each partition would deliver to this group, i.e. the message at its committed offset, then exit. Nothing is committed")
+}
+
+// consumeGroupSnapshot emits the message at the committed offset of group
+// for each partition of topic.
+func consumeGroupSnapshot(client sarama.Client, topic string, partitions []int32, group string, emit func(*sarama.ConsumerMessage)) {
+	committed, err := fetchGroupOffsets(client, group, topic, partitions)
+	if err != nil {
+		errorExit("Unable to fetch offsets of group %v: %v\n", group, err)
+	}
+	oldestOffsets := getOldestOffsetsFromClient(client, topic, partitions)
+	highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+	starts := make(map[int32]int64)
+	ends := make(map[int32]int64)
+	var peeked []int32
+	for _, partition := range partitions {
+		offset, ok := committed[partition]
+		switch {
+		case !ok:
+			fmt.Fprintf(os.Stderr, "Partition %v: no committed offset\n", partition)
+			continue
+		case offset >= highWatermarks[partition]:
+			fmt.Fprintf(os.Stderr, "Partition %v: caught up at offset %v\n", partition, offset)
+			continue
+		case offset < oldestOffsets[partition]:
+			fmt.Fprintf(os.Stderr, "Partition %v: committed offset %v was deleted, the group resets to its offset reset policy\n", partition, offset)
+			continue
+		}
+		starts[partition] = offset
+		ends[partition] = offset + 1
+		peeked = append(peeked, partition)
+	}
+	if len(peeked) == 0 {
+		return
+	}
+
+	opts := readOptions{
+		start: starts,
+		end:   ends,
+		stop:  stopConsume,
+		// On transactional topics, the committed offset of a caught up
+		// group may point at a commit marker, which is never delivered.
+		// Otherwise the next message is the one the group gets next.
+		untilHWM: true,
+		eofIdle:  fetchIdleTimeout(client.Config()),
+		caughtUp: func(partition int32) {
+			fmt.Fprintf(os.Stderr, "Partition %v: caught up at offset %v\n", partition, starts[partition])
+		},
+	}
+	if err := consumePartitions(client, topic, peeked, opts, emit); err != nil {
+		errorExit("Unable to consume partition: %v\n", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	headerFilterFlags []string
+	headerFilters     []*headerFilter
+)
+
+func init() {
+	consumeCmd.Flags().StringArrayVar(&headerFilterFlags, "header-filter", nil, "Only print messages with a header matching a comparison, given as <header><op><value> with op one of =, !=, <, <=, >, >= or ~ (regex), e.g. 'seq>1000'. Values are compared as numbers if both are numeric, including Azure Event Hubs encoded numbers. May be repeated, all filters must match")
+}
+
+// Header value type codes of the AMQP encoding used by Azure Event Hubs.
+const (
+	amqpString8   = 0xa1
+	amqpTimestamp = 0x83
+)
+
+// headerValue is a decoded header value. number is set if the value is
+// numeric, either as AMQP number or as text.
+type headerValue struct {
+	text   string
+	number *big.Rat
+}
+
+// decodeHeaderValue decodes a header value, detecting the AMQP encoding of
+// strings and numbers used by Azure Event Hubs.
+func decodeHeaderValue(b []byte) headerValue {
+	switch {
+	case len(b) >= 2 && b[0] == amqpString8 && len(b) >= 2+int(b[1]):
+		return newHeaderValue(string(b[2 : 2+b[1]]))
+	case len(b) >= 9 && b[0] == amqpTimestamp:
+		n := binary.BigEndian.Uint64(b[1:9])
+		return headerValue{
+			text:   strconv.FormatUint(n, 10),
+			number: new(big.Rat).SetInt(new(big.Int).SetUint64(n)),
+		}
+	default:
+		return newHeaderValue(string(b))
+	}
+}
+
+// newHeaderValue returns the header value of text, which is numeric if text
+// is a number.
+func newHeaderValue(text string) headerValue {
+	v := headerValue{text: text}
+	if n, ok := new(big.Rat).SetString(strings.TrimSpace(text)); ok && text != "" {
+		v.number = n
+	}
+	return v
+}
+
+// headerFilter matches messages with a header whose value compares to a
+// given value.
+type headerFilter struct {
+	header  string
+	op      string
+	value   headerValue
+	pattern *regexp.Regexp
+}
+
+// headerFilterOps are the operators of headerFilter, two character operators
+// first.
+var headerFilterOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// parseHeaderFilter parses a filter of the form <header><op><value>.
+func parseHeaderFilter(s string) (*headerFilter, error) {
+	i := strings.IndexAny(s, "=!<>~")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid header filter %v: expected <header><op><value>", s)
+	}
+	for _, op := range headerFilterOps {
+		if !strings.HasPrefix(s[i:], op) {
+			continue
+		}
+		f := &headerFilter{header: s[:i], op: op, value: newHeaderValue(s[i+len(op):])}
+		switch op {
+		case "~":
+			pattern, err := regexp.Compile(f.value.text)
+			if err != nil {
+				return nil, err
+			}
+			f.pattern = pattern
+		case "<", "<=", ">", ">=":
+			if f.value.number == nil {
+				return nil, fmt.Errorf("invalid header filter %v: %v requires a number", s, op)
+			}
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid header filter %v: expected <header><op><value>", s)
+}
+
+// matches returns whether any header of the filtered name matches.
+func (f *headerFilter) matches(headers []*sarama.RecordHeader) bool {
+	for _, hdr := range headers {
+		if string(hdr.Key) == f.header && f.matchesValue(decodeHeaderValue(hdr.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *headerFilter) matchesValue(v headerValue) bool {
+	if f.op == "~" {
+		return f.pattern.MatchString(v.text)
+	}
+
+	numeric := v.number != nil && f.value.number != nil
+	switch f.op {
+	case "=":
+		return numeric && v.number.Cmp(f.value.number) == 0 || !numeric && v.text == f.value.text
+	case "!=":
+		return numeric && v.number.Cmp(f.value.number) != 0 || !numeric && v.text != f.value.text
+	}
+	if !numeric {
+		return false
+	}
+	c := v.number.Cmp(f.value.number)
+	switch f.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var (
+	idFieldFlag       string
+	sinceIDFlag       int64
+	untilIDFlag       int64
+	warnMissingIDFlag bool
+	ids               *idRange
+)
+
+func init() {
+	consumeCmd.Flags().StringVar(&idFieldFlag, "id-field", "", "JSONPath of a monotonically increasing integer id in decoded values, used by --since-id and --until-id")
+	consumeCmd.Flags().Int64Var(&sinceIDFlag, "since-id", 0, "Only print messages whose --id-field is at least this id")
+	consumeCmd.Flags().Int64Var(&untilIDFlag, "until-id", 0, "Only print messages whose --id-field is at most this id. A partition is no longer printed once it exceeded the id, consuming stops once all partitions did")
+	consumeCmd.Flags().BoolVar(&warnMissingIDFlag, "warn-missing-id", false, "Print a warning for messages skipped because they lack --id-field")
+}
+
+// idRange selects messages by an id field of their value. Ids are assumed
+// to increase within each partition.
+type idRange struct {
+	path     jsonPath
+	since    int64
+	hasSince bool
+	until    int64
+	hasUntil bool
+
+	mu         sync.Mutex
+	partitions int
+	exceeded   map[int32]bool
+}
+
+func newIDRange(path jsonPath, partitions int) *idRange {
+	return &idRange{path: path, partitions: partitions, exceeded: make(map[int32]bool)}
+}
+
+// contains returns true if the id of value lies within the range. Once a
+// partition exceeded the range, none of its later messages is contained.
+func (r *idRange) contains(partition int32, offset int64, value []byte) bool {
+	r.mu.Lock()
+	exceeded := r.exceeded[partition]
+	r.mu.Unlock()
+	if exceeded {
+		return false
+	}
+
+	field, ok := r.path.extract(value)
+	if !ok {
+		if warnMissingIDFlag {
+			fmt.Fprintf(os.Stderr, "Warning: skipping message at partition %v offset %v without id field\n", partition, offset)
+		}
+		return false
+	}
+	id, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		if warnMissingIDFlag {
+			fmt.Fprintf(os.Stderr, "Warning: skipping message at partition %v offset %v with invalid id %v\n", partition, offset, field)
+		}
+		return false
+	}
+
+	if r.hasSince && id < r.since {
+		return false
+	}
+	if r.hasUntil && id > r.until {
+		r.mu.Lock()
+		r.exceeded[partition] = true
+		done := len(r.exceeded) >= r.partitions
+		r.mu.Unlock()
+		if done {
+			stopConsuming()
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath expression. Only child operators are
+// supported: $.field, $['field'] and $[index]. Each element is either a
+// field name or an array index.
+type jsonPath []interface{}
+
+func parseJSONPath(s string) (jsonPath, error) {
+	expr := strings.TrimPrefix(s, "$")
+	var path jsonPath
+
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '.':
+			end := strings.IndexAny(expr[1:], ".[")
+			if end < 0 {
+				end = len(expr) - 1
+			}
+			field := expr[1 : 1+end]
+			if field == "" {
+				return nil, fmt.Errorf("invalid JSONPath %v: empty field name", s)
+			}
+			path = append(path, field)
+			expr = expr[1+end:]
+		case '[':
+			end := strings.IndexByte(expr, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %v: missing ]", s)
+			}
+			subscript := expr[1:end]
+			if len(subscript) >= 2 && (subscript[0] == '\'' || subscript[0] == '"') && subscript[len(subscript)-1] == subscript[0] {
+				path = append(path, subscript[1:len(subscript)-1])
+			} else if index, err := strconv.Atoi(subscript); err == nil && index >= 0 {
+				path = append(path, index)
+			} else {
+				return nil, fmt.Errorf("invalid JSONPath %v: unsupported subscript [%v]", s, subscript)
+			}
+			expr = expr[end+1:]
+		default:
+			if len(path) > 0 || strings.HasPrefix(s, "$") {
+				return nil, fmt.Errorf("invalid JSONPath %v: unexpected %q", s, expr[0])
+			}
+			// Allow the leading $. to be omitted.
+			expr = "." + expr
+		}
+	}
+	return path, nil
+}
+
+// eval returns the element of v the path points to. It returns false if the
+// element does not exist.
+func (p jsonPath) eval(v interface{}) (interface{}, bool) {
+	for _, elem := range p {
+		switch elem := elem.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[elem]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := v.([]interface{})
+			if !ok || elem >= len(arr) {
+				return nil, false
+			}
+			v = arr[elem]
+		}
+	}
+	return v, true
+}
+
+// extract parses data as JSON and returns the element the path points to,
+// formatted by formatJSONValue. It returns false if data is not JSON or the
+// element does not exist.
+func (p jsonPath) extract(data []byte) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+
+	v, ok := p.eval(v)
+	if !ok {
+		return "", false
+	}
+	return formatJSONValue(v), true
+}
+
+// formatJSONValue formats strings as they are and all other values as JSON.
+func formatJSONValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// valueFilter matches messages whose value has an element matching a regex.
+type valueFilter struct {
+	path    jsonPath
+	pattern *regexp.Regexp
+}
+
+// parseValueFilter parses a filter of the form <jsonpath>=<regex>.
+func parseValueFilter(s string) (*valueFilter, error) {
+	// The path may contain = inside of a quoted subscript, so split at the
+	// first = outside of brackets.
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '=':
+			if depth > 0 {
+				continue
+			}
+			path, err := parseJSONPath(s[:i])
+			if err != nil {
+				return nil, err
+			}
+			pattern, err := regexp.Compile(s[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			return &valueFilter{path: path, pattern: pattern}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid filter %v: expected <jsonpath>=<regex>", s)
+}
+
+func (f *valueFilter) matches(value []byte) bool {
+	extracted, ok := f.path.extract(value)
+	return ok && f.pattern.MatchString(extracted)
+}
@@ -7,10 +7,12 @@ import (
 	"crypto/x509"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 
 	"github.com/Shopify/sarama"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/proxy"
 
 	"github.com/birdayz/kaf"
 	"github.com/birdayz/kaf/avro"
@@ -18,16 +20,30 @@ import (
 
 var cfgFile string
 
+// version is set at build time via -ldflags "-X main.version=...".
+var version = "dev"
+
 func getConfig() (saramaConfig *sarama.Config) {
 	saramaConfig = sarama.NewConfig()
 	saramaConfig.Version = sarama.V0_11_0_0
 	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.ClientID = clientIDFlag
+
+	if dialer := getProxyDialer(); dialer != nil {
+		saramaConfig.Net.Proxy.Enable = true
+		saramaConfig.Net.Proxy.Dialer = dialer
+	}
 
 	cluster := currentCluster
+	if cluster.KafkaVersion != "" {
+		version, err := sarama.ParseKafkaVersion(cluster.KafkaVersion)
+		if err != nil {
+			errorExit("Invalid kafka-version %v: %v\n", cluster.KafkaVersion, err)
+		}
+		saramaConfig.Version = version
+	}
 	if cluster.SASL != nil {
-		saramaConfig.Net.SASL.Enable = true
-		saramaConfig.Net.SASL.User = cluster.SASL.Username
-		saramaConfig.Net.SASL.Password = cluster.SASL.Password
+		configureSASL(saramaConfig, cluster)
 	}
 	if cluster.SecurityProtocol == "SASL_SSL" {
 		saramaConfig.Net.TLS.Enable = true
@@ -45,15 +61,52 @@ func getConfig() (saramaConfig *sarama.Config) {
 				caCertPool.AppendCertsFromPEM(caCert)
 				tlsConfig.RootCAs = caCertPool
 			}
+			if cluster.TLS.Clientfile != "" {
+				cert, err := tls.LoadX509KeyPair(cluster.TLS.Clientfile, cluster.TLS.Clientkeyfile)
+				if err != nil {
+					errorExit("Unable to load client certificate: %v\n", err)
+				}
+				tlsConfig.Certificates = []tls.Certificate{cert}
+			}
 			saramaConfig.Net.TLS.Config = tlsConfig
 
 		} else {
 			saramaConfig.Net.TLS.Config = &tls.Config{InsecureSkipVerify: false}
 		}
+		if cluster.TLS != nil && cluster.TLS.ServerName != "" {
+			saramaConfig.Net.TLS.Config.ServerName = cluster.TLS.ServerName
+		}
+		if tlsServerNameFlag != "" {
+			saramaConfig.Net.TLS.Config.ServerName = tlsServerNameFlag
+		}
+	} else if tlsServerNameFlag != "" {
+		errorExit("--tls-server-name requires security-protocol SASL_SSL\n")
 	}
 	return saramaConfig
 }
 
+// getProxyDialer returns a dialer connecting through the proxy given by
+// --proxy or $ALL_PROXY, or nil if neither is set.
+func getProxyDialer() proxy.Dialer {
+	proxyURL := proxyFlag
+	if proxyURL == "" {
+		proxyURL = os.Getenv("ALL_PROXY")
+	}
+	if proxyURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		errorExit("Invalid proxy URL %v: %v\n", proxyURL, err)
+	}
+	dialer, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		errorExit("Unable to use proxy %v: %v\n", proxyURL, err)
+	}
+	return dialer
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "kaf",
 	Short: "Kafka Command Line utility for cluster management",
@@ -72,12 +125,29 @@ var currentCluster *kaf.Cluster
 var brokersFlag []string
 var schemaRegistryURL string
 var verbose bool
+var clusterFlag string
+var clientIDFlag string
+var proxyFlag string
+var authFileFlag string
+var noAvroFlag bool
+var tlsServerNameFlag string
+var quietFlag bool
+
+// clusterEnvVar selects the cluster to use if no --cluster flag is given.
+const clusterEnvVar = "KAF_CLUSTER"
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kaf/config)")
 	rootCmd.PersistentFlags().StringSliceVarP(&brokersFlag, "brokers", "b", nil, "Comma separated list of broker ip:port pairs")
 	rootCmd.PersistentFlags().StringVar(&schemaRegistryURL, "schema-registry", "", "URL to a Confluent schema registry. Used for attempting to decode Avro-encoded messages")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Whether to turn on sarama logging")
+	rootCmd.PersistentFlags().StringVar(&clusterFlag, "cluster", "", "Name of the configured cluster to use. Overrides $KAF_CLUSTER and the current cluster of the config file")
+	rootCmd.PersistentFlags().StringVar(&clientIDFlag, "client-id", "kaf-"+version, "Client ID sent to the brokers, e.g. to identify kaf in request logs and quotas")
+	rootCmd.PersistentFlags().StringVar(&proxyFlag, "proxy", "", "SOCKS5 proxy to connect to the brokers through, e.g. socks5://localhost:1080 (default $ALL_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&tlsServerNameFlag, "tls-server-name", "", "Host name broker certificates are verified against, e.g. if brokers are reached through a load balancer. Overrides TLS server-name of the cluster config")
+	rootCmd.PersistentFlags().BoolVar(&noAvroFlag, "no-avro", false, "Never contact the schema registry. Avro-encoded keys and values are printed as raw bytes, including the schema registry header")
+	rootCmd.PersistentFlags().StringVar(&authFileFlag, "auth-from-file", "", "YAML or JSON file with credentials, keeping them out of the command line. Possible keys: sasl_username, sasl_password, schema_registry_user, schema_registry_pass, tls_cafile, tls_certfile, tls_keyfile, oauth_token, oauth_client_secret. Must not be readable by other users")
+	rootCmd.PersistentFlags().BoolVarP(&quietFlag, "quiet", "q", false, "Suppress informational messages like \"Created topic X.\". Errors and data are still printed, the exit code signals success")
 	cobra.OnInitialize(onInit)
 }
 
@@ -85,10 +155,19 @@ func onInit() {
 	var err error
 	config, err = kaf.ReadConfig()
 	if err != nil && !os.IsNotExist(err) {
-		errorExit("Config %s does not exist", config)
+		errorExit("Unable to read config: %v\n", err)
 	}
+	applyConfigDefaults()
 
+	// Precedence: --cluster flag, $KAF_CLUSTER, current cluster of the config.
 	cluster := config.ActiveCluster()
+	if name, ok := clusterOverride(); ok {
+		cluster = config.Cluster(name)
+		if cluster == nil {
+			errorExit("Could not find cluster with name %v\n", name)
+		}
+	}
+
 	if cluster != nil {
 		// Use active cluster from config
 		currentCluster = cluster
@@ -108,12 +187,44 @@ func onInit() {
 		currentCluster.Brokers = brokersFlag
 	}
 
+	if authFileFlag != "" {
+		auth, err := readAuthFile(authFileFlag)
+		if err != nil {
+			errorExit("Unable to read auth file: %v\n", err)
+		}
+		// Apply the credentials to a copy, so that they never end up in
+		// the config file if it is written.
+		currentCluster = currentCluster.Clone()
+		if err := auth.apply(currentCluster); err != nil {
+			errorExit("Unable to apply auth file: %v\n", err)
+		}
+	}
+
+	if saslFlagsSet() {
+		// Like credentials of the auth file, SASL flags must never end
+		// up in the config file.
+		currentCluster = currentCluster.Clone()
+		applySASLFlags(currentCluster)
+	}
+
 	if verbose {
 		sarama.Logger = log.New(os.Stderr, "[sarama] ", log.Lshortfile|log.LstdFlags)
 	}
 
 }
 
+// clusterOverride returns the cluster name selected via the --cluster flag or
+// the KAF_CLUSTER environment variable, if any.
+func clusterOverride() (name string, ok bool) {
+	if clusterFlag != "" {
+		return clusterFlag, true
+	}
+	if env := os.Getenv(clusterEnvVar); env != "" {
+		return env, true
+	}
+	return "", false
+}
+
 func getClusterAdmin() (admin sarama.ClusterAdmin) {
 	clusterAdmin, err := sarama.NewClusterAdmin(currentCluster.Brokers, getConfig())
 	if err != nil {
@@ -122,8 +233,30 @@ func getClusterAdmin() (admin sarama.ClusterAdmin) {
 	return clusterAdmin
 }
 
+// getClusterAdminOf returns a cluster admin of cluster, which may differ from
+// the current cluster.
+func getClusterAdminOf(cluster *kaf.Cluster) sarama.ClusterAdmin {
+	current := currentCluster
+	currentCluster = cluster
+	defer func() { currentCluster = current }()
+	return getClusterAdmin()
+}
+
+// getClientOf returns a client of cluster, which may differ from the current
+// cluster.
+func getClientOf(cluster *kaf.Cluster) sarama.Client {
+	current := currentCluster
+	currentCluster = cluster
+	defer func() { currentCluster = current }()
+	return getClient()
+}
+
 func getClient() (client sarama.Client) {
-	client, err := sarama.NewClient(currentCluster.Brokers, getConfig())
+	return getClientFromConfig(getConfig())
+}
+
+func getClientFromConfig(config *sarama.Config) (client sarama.Client) {
+	client, err := sarama.NewClient(currentCluster.Brokers, config)
 	if err != nil {
 		errorExit("Unable to get client: %v\n", err)
 	}
@@ -131,7 +264,7 @@ func getClient() (client sarama.Client) {
 }
 
 func getSchemaCache() (cache *avro.SchemaCache) {
-	if currentCluster.SchemaRegistryURL == "" {
+	if noAvroFlag || currentCluster.SchemaRegistryURL == "" {
 		return nil
 	}
 	cache, err := avro.NewSchemaCache(currentCluster.SchemaRegistryURL)
@@ -141,7 +274,34 @@ func getSchemaCache() (cache *avro.SchemaCache) {
 	return cache
 }
 
+// getSchemaCacheOf returns the schema cache of cluster, which may differ from
+// the current cluster, or nil if it has no schema registry.
+func getSchemaCacheOf(cluster *kaf.Cluster) *avro.SchemaCache {
+	current := currentCluster
+	currentCluster = cluster
+	defer func() { currentCluster = current }()
+	return getSchemaCache()
+}
+
+// printInfo prints an informational message to stdout unless --quiet is set.
+func printInfo(format string, a ...interface{}) {
+	if !quietFlag {
+		fmt.Printf(format, a...)
+	}
+}
+
+// printNotice prints an informational message to stderr unless --quiet is
+// set.
+func printNotice(format string, a ...interface{}) {
+	if !quietFlag {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
 func errorExit(format string, a ...interface{}) {
+	// The error is printed after the output written to the pager.
+	closeExitOutput()
+	closePager()
 	fmt.Fprintf(os.Stderr, format, a...)
 	os.Exit(1)
 }
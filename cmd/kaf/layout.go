@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// Output layouts of list commands. The default layout is used if neither
+// flag is set.
+var (
+	wideFlag          bool
+	compactLayoutFlag bool
+)
+
+// addLayoutFlags registers --wide and --compact on a list command.
+func addLayoutFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&wideFlag, "wide", false, "Print additional columns")
+	cmd.Flags().BoolVar(&compactLayoutFlag, "compact", false, "Only print the essential columns")
+}
+
+// checkLayoutFlags exits if both --wide and --compact are set.
+func checkLayoutFlags() {
+	if wideFlag && compactLayoutFlag {
+		errorExit("Only one of --wide and --compact may be set\n")
+	}
+}
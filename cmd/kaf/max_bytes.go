@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	maxBytesTotalFlag string
+	maxBytesTotal     int64
+	printedBytes      int64
+	printedMessages   int64
+	maxBytesReached   int32
+)
+
+func init() {
+	consumeCmd.Flags().StringVar(&maxBytesTotalFlag, "max-bytes-total", "", "Stop once the values printed in total would exceed this size, e.g. 500KB, 100MB or 1GB (multiples of 1024)")
+}
+
+// byteUnits are the units accepted by parseByteSize, longest suffix first.
+var byteUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"tb", 1 << 40},
+	{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"t", 1 << 40},
+	{"b", 1},
+}
+
+// parseByteSize parses a human readable size like 10MB or 1.5GB into bytes.
+// A number without unit is a number of bytes.
+func parseByteSize(s string) (int64, error) {
+	number, factor := strings.ToLower(strings.TrimSpace(s)), int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, factor = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %v", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// countBytes accounts for a value about to be printed. It returns false and
+// stops consuming if printing it would exceed --max-bytes-total.
+func countBytes(n int) bool {
+	if maxBytesTotal <= 0 {
+		return true
+	}
+
+	for {
+		printed := atomic.LoadInt64(&printedBytes)
+		if printed+int64(n) > maxBytesTotal {
+			atomic.StoreInt32(&maxBytesReached, 1)
+			stopConsuming()
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&printedBytes, printed, printed+int64(n)) {
+			atomic.AddInt64(&printedMessages, 1)
+			return true
+		}
+	}
+}
+
+// printMaxBytesSummary prints the totals if consuming stopped at
+// --max-bytes-total.
+func printMaxBytesSummary() {
+	if atomic.LoadInt32(&maxBytesReached) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Stopped at --max-bytes-total after printing %v bytes of values in %v messages.\n", atomic.LoadInt64(&printedBytes), atomic.LoadInt64(&printedMessages))
+}
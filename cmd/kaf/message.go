@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Shopify/sarama"
+)
+
+// jsonMessage is the JSON representation of a record, as printed by
+// `consume --output json` and read by `produce --input json`.
+//
+// Keys and values which are valid UTF-8 are represented as strings, binary
+// data is base64 encoded into the *_b64 fields instead. Values which are
+// compact JSON objects, arrays, numbers or literals other than null are
+// embedded as-is, all other values are strings. A null value,
+// e.g. a tombstone, is represented as JSON null. Headers are represented as
+// described at jsonHeaders.
+type jsonMessage struct {
+	Partition *int32          `json:"partition,omitempty"`
+	Offset    *int64          `json:"offset,omitempty"`
+	Timestamp *time.Time      `json:"timestamp,omitempty"`
+	Headers   jsonHeaders     `json:"headers,omitempty"`
+	Key       *string         `json:"key,omitempty"`
+	KeyB64    []byte          `json:"key_b64,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	ValueB64  []byte          `json:"value_b64,omitempty"`
+	// GroupGeneration and MemberID are only set with --group-generation.
+	GroupGeneration *int32 `json:"group_generation,omitempty"`
+	MemberID        string `json:"member_id,omitempty"`
+}
+
+// newJSONMessage builds the JSON representation of msg, using the already
+// decoded key and value.
+func newJSONMessage(msg *sarama.ConsumerMessage, key, value []byte) jsonMessage {
+	ts := localTime(msg.Timestamp)
+	m := jsonMessage{
+		Partition: &msg.Partition,
+		Offset:    &msg.Offset,
+		Timestamp: &ts,
+	}
+
+	m.Headers = newJSONHeaders(msg.Headers)
+
+	if key != nil {
+		if utf8.Valid(key) {
+			k := string(key)
+			m.Key = &k
+		} else {
+			m.KeyB64 = key
+		}
+	}
+
+	switch {
+	case value == nil:
+		m.Value = json.RawMessage("null")
+	case isEmbeddableJSON(value):
+		m.Value = value
+	case utf8.Valid(value):
+		m.Value, _ = json.Marshal(string(value))
+	default:
+		m.ValueB64 = value
+	}
+
+	return m
+}
+
+// isEmbeddableJSON returns true if b is valid JSON which can be embedded
+// without ambiguity or loss. JSON strings and null are excluded, as they
+// could not be told apart from a plain string value or a null value. JSON
+// which is changed by encoding, like whitespace or HTML characters, is
+// excluded so that the value is produced back with identical bytes.
+func isEmbeddableJSON(b []byte) bool {
+	if len(b) == 0 || b[0] == '"' || bytes.Equal(b, []byte("null")) {
+		return false
+	}
+	encoded, err := json.Marshal(json.RawMessage(b))
+	return err == nil && bytes.Equal(encoded, b)
+}
+
+// key returns the raw key of m.
+func (m *jsonMessage) key() ([]byte, error) {
+	if m.Key != nil && m.KeyB64 != nil {
+		return nil, errors.New("only one of key and key_b64 may be set")
+	}
+	if m.Key != nil {
+		return []byte(*m.Key), nil
+	}
+	return m.KeyB64, nil
+}
+
+// value returns the raw value of m.
+func (m *jsonMessage) value() ([]byte, error) {
+	if m.Value != nil && !bytes.Equal(m.Value, []byte("null")) && m.ValueB64 != nil {
+		return nil, errors.New("only one of value and value_b64 may be set")
+	}
+	if m.Value == nil || bytes.Equal(m.Value, []byte("null")) {
+		return m.ValueB64, nil
+	}
+	var s string
+	if err := json.Unmarshal(m.Value, &s); err == nil {
+		return []byte(s), nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, m.Value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonHeaders is the JSON representation of record headers, a map of header
+// keys to values. Values which are valid UTF-8 are represented as strings,
+// binary values as objects of the form {"b64": "<base64>"}.
+type jsonHeaders map[string]jsonHeaderValue
+
+// jsonHeaderValue is the value of a header, see jsonHeaders.
+type jsonHeaderValue []byte
+
+func newJSONHeaders(headers []*sarama.RecordHeader) jsonHeaders {
+	if len(headers) == 0 {
+		return nil
+	}
+	h := make(jsonHeaders, len(headers))
+	for _, hdr := range headers {
+		h[string(hdr.Key)] = hdr.Value
+	}
+	return h
+}
+
+// parseJSONHeaders parses headers in the format described at jsonHeaders.
+func parseJSONHeaders(s string) (jsonHeaders, error) {
+	var h jsonHeaders
+	if err := json.Unmarshal([]byte(s), &h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// recordHeaders returns the headers as record headers, sorted by key.
+func (h jsonHeaders) recordHeaders() []sarama.RecordHeader {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	headers := make([]sarama.RecordHeader, 0, len(h))
+	for _, k := range keys {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: h[k]})
+	}
+	return headers
+}
+
+func (v jsonHeaderValue) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(v) {
+		return json.Marshal(string(v))
+	}
+	return json.Marshal(struct {
+		B64 []byte `json:"b64"`
+	}{v})
+}
+
+func (v *jsonHeaderValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = []byte(s)
+		return nil
+	}
+
+	var binary struct {
+		B64 *string `json:"b64"`
+	}
+	if err := json.Unmarshal(b, &binary); err != nil || binary.B64 == nil {
+		return errors.New("header values must be strings or objects of the form {\"b64\": \"...\"}")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(*binary.B64)
+	if err != nil {
+		return err
+	}
+	*v = decoded
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestJSONMessageRoundTrip(t *testing.T) {
+	values := []string{
+		`{"a":1}`,
+		`{"a": 1}`,
+		`[1,2]`,
+		` 42`,
+		`42`,
+		`true`,
+		`null`,
+		`"quoted"`,
+		`{"html":"<b>&</b>"}`,
+		"plain text",
+		"\xff\xfe",
+		"",
+	}
+	for _, v := range values {
+		value := []byte(v)
+		b, err := json.Marshal(newJSONMessage(&sarama.ConsumerMessage{}, nil, value))
+		if err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+		var m jsonMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+		got, err := m.value()
+		if err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+		if got == nil || !bytes.Equal(got, value) {
+			t.Errorf("value %q round-tripped as %q through %s", v, got, b)
+		}
+	}
+}
+
+func TestJSONMessageTombstone(t *testing.T) {
+	b, err := json.Marshal(newJSONMessage(&sarama.ConsumerMessage{}, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m jsonMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.value(); err != nil || got != nil {
+		t.Errorf("tombstone round-tripped as %q, %v through %s", got, err, b)
+	}
+}
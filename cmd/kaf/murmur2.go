@@ -0,0 +1,49 @@
+package main
+
+import "encoding/binary"
+
+// murmur2 computes the 32 bit murmur2 hash of data exactly like
+// org.apache.kafka.common.utils.Utils.murmur2 of the Java client.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// javaDefaultPartition returns the partition the default partitioner of the
+// Java client assigns to a record with a non-null key.
+func javaDefaultPartition(key []byte, numPartitions int32) int32 {
+	return (murmur2(key) & 0x7fffffff) % numPartitions
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+// Test vectors of org.apache.kafka.common.utils.UtilsTest.testMurmur2.
+var murmur2Tests = []struct {
+	data string
+	hash int32
+}{
+	{"21", -973932308},
+	{"foobar", -790332482},
+	{"a-little-bit-long-string", -985981536},
+	{"a-little-bit-longer-string", -1486304829},
+	{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+	{"abc", 479470107},
+}
+
+func TestMurmur2(t *testing.T) {
+	for _, tt := range murmur2Tests {
+		if got := murmur2([]byte(tt.data)); got != tt.hash {
+			t.Errorf("murmur2(%q) = %v, want %v", tt.data, got, tt.hash)
+		}
+	}
+}
+
+func TestJavaDefaultPartition(t *testing.T) {
+	// toPositive(murmur2(key)) % numPartitions, as computed by the Java
+	// client, for 1, 3 and 12 partitions.
+	tests := []struct {
+		key        string
+		partitions [3]int32
+	}{
+		{"21", [3]int32{0, 0, 0}},
+		{"foobar", [3]int32{0, 0, 6}},
+		{"a-little-bit-long-string", [3]int32{0, 2, 8}},
+		{"a-little-bit-longer-string", [3]int32{0, 2, 11}},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", [3]int32{0, 2, 5}},
+		{"abc", [3]int32{0, 0, 3}},
+	}
+	for _, tt := range tests {
+		for i, numPartitions := range []int32{1, 3, 12} {
+			if got := javaDefaultPartition([]byte(tt.key), numPartitions); got != tt.partitions[i] {
+				t.Errorf("javaDefaultPartition(%q, %v) = %v, want %v", tt.key, numPartitions, got, tt.partitions[i])
+			}
+		}
+	}
+}
@@ -3,17 +3,24 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"text/tabwriter"
 
 	"sort"
 
+	"github.com/Shopify/sarama"
 	"github.com/spf13/cobra"
 )
 
+var allBrokersFlag bool
+
 func init() {
 	rootCmd.AddCommand(nodeCommand)
 	nodeCommand.AddCommand(nodeLsCommand)
+	nodeCommand.AddCommand(nodeDescribeCommand)
 	nodeLsCommand.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	addLayoutFlags(nodeLsCommand)
+	nodeDescribeCommand.Flags().BoolVar(&allBrokersFlag, "all", false, "Describe all brokers of the cluster")
 }
 
 var nodesCommand = &cobra.Command{
@@ -23,17 +30,19 @@ var nodesCommand = &cobra.Command{
 }
 
 var nodeCommand = &cobra.Command{
-	Use:   "node",
-	Short: "Describe and List nodes",
+	Use:     "node",
+	Aliases: []string{"broker", "brokers"},
+	Short:   "Describe and List nodes",
 }
 
 var nodeLsCommand = &cobra.Command{
 	Use:   "ls",
 	Short: "List nodes in a cluster",
 	Run: func(cmd *cobra.Command, args []string) {
+		checkLayoutFlags()
 		admin := getClusterAdmin()
 
-		brokers, _, err := admin.DescribeCluster()
+		brokers, controllerID, err := admin.DescribeCluster()
 		if err != nil {
 			errorExit("Unable to describe cluster: %v\n", err)
 		}
@@ -44,13 +53,100 @@ var nodeLsCommand = &cobra.Command{
 
 		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 		if !noHeaderFlag {
-			fmt.Fprintf(w, "ID\tADDRESS\t\n")
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "ID\t\n")
+			case wideFlag:
+				fmt.Fprintf(w, "ID\tADDRESS\tCONTROLLER\t\n")
+			default:
+				fmt.Fprintf(w, "ID\tADDRESS\t\n")
+			}
 		}
 
 		for _, broker := range brokers {
-			fmt.Fprintf(w, "%v\t%v\t\n", broker.ID(), broker.Addr())
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "%v\t\n", broker.ID())
+			case wideFlag:
+				fmt.Fprintf(w, "%v\t%v\t%v\t\n", broker.ID(), broker.Addr(), broker.ID() == controllerID)
+			default:
+				fmt.Fprintf(w, "%v\t%v\t\n", broker.ID(), broker.Addr())
+			}
 		}
 
 		w.Flush()
 	},
 }
+
+var nodeDescribeCommand = &cobra.Command{
+	Use:   "describe [ID]",
+	Short: "Describe the configuration of a node",
+	Long:  "Describe the configuration of a node. Default values of the configuration are omitted.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if allBrokersFlag == (len(args) == 1) {
+			errorExit("Either a node ID or --all is required\n")
+		}
+
+		client := getClient()
+		brokers := client.Brokers()
+		sort.Slice(brokers, func(i, j int) bool {
+			return brokers[i].ID() < brokers[j].ID()
+		})
+
+		if !allBrokersFlag {
+			id, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil {
+				errorExit("Invalid node ID %v\n", args[0])
+			}
+			var found []*sarama.Broker
+			for _, broker := range brokers {
+				if broker.ID() == int32(id) {
+					found = append(found, broker)
+				}
+			}
+			if len(found) == 0 {
+				errorExit("Node %v not found.\n", id)
+			}
+			brokers = found
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		for i, broker := range brokers {
+			cfg, err := describeBrokerConfig(client, broker)
+			if err != nil {
+				errorExit("Unable to describe config of node %v: %v\n", broker.ID(), err)
+			}
+
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "ID:\t%v\t\n", broker.ID())
+			fmt.Fprintf(w, "Address:\t%v\t\n", broker.Addr())
+			fmt.Fprintf(w, "Config:\n")
+			w.Flush()
+			w.Init(os.Stdout, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
+
+			fmt.Fprintf(w, "\tName\tValue\tReadOnly\tSensitive\t\n")
+			fmt.Fprintf(w, "\t----\t-----\t--------\t---------\t\n")
+			for _, entry := range cfg {
+				if entry.Default {
+					continue
+				}
+				fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t\n", entry.Name, entry.Value, entry.ReadOnly, entry.Sensitive)
+			}
+			w.Flush()
+			w.Init(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		}
+	},
+}
+
+// describeBrokerConfig fetches the configuration of a broker. Broker configs
+// can only be described by the broker itself, so the request is sent to it
+// directly instead of to the controller.
+func describeBrokerConfig(client sarama.Client, broker *sarama.Broker) ([]*sarama.ConfigEntry, error) {
+	return describeResourceConfig(client, broker, sarama.ConfigResource{
+		Type: sarama.BrokerResource,
+		Name: strconv.Itoa(int(broker.ID())),
+	}, 0, false)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/birdayz/kaf"
+)
+
+var (
+	saslMechanismFlag     string
+	oauthTokenFlag        string
+	oauthTokenCommandFlag string
+	oauthEndpointFlag     string
+	oauthClientIDFlag     string
+	oauthClientSecretFlag string
+	oauthScopesFlag       []string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&saslMechanismFlag, "sasl-mechanism", "", "SASL mechanism. Possible values: PLAIN, OAUTHBEARER. Overrides the mechanism of the cluster config")
+	rootCmd.PersistentFlags().StringVar(&oauthTokenFlag, "oauth-token", "", "Static access token for OAUTHBEARER")
+	rootCmd.PersistentFlags().StringVar(&oauthTokenCommandFlag, "oauth-token-command", "", "Shell command printing an access token for OAUTHBEARER, run whenever a new token is needed")
+	rootCmd.PersistentFlags().StringVar(&oauthEndpointFlag, "oauth-endpoint", "", "OAuth 2.0 token endpoint to fetch OAUTHBEARER access tokens from with the client credentials grant")
+	rootCmd.PersistentFlags().StringVar(&oauthClientIDFlag, "oauth-client-id", "", "Client ID for --oauth-endpoint")
+	rootCmd.PersistentFlags().StringVar(&oauthClientSecretFlag, "oauth-client-secret", "", "Client secret for --oauth-endpoint. Prefer oauth_client_secret of --auth-from-file")
+	rootCmd.PersistentFlags().StringSliceVar(&oauthScopesFlag, "oauth-scopes", nil, "Comma separated scopes requested from --oauth-endpoint")
+}
+
+// saslFlagsSet returns true if any SASL flag is set.
+func saslFlagsSet() bool {
+	return saslMechanismFlag != "" || oauthFlagsSet()
+}
+
+func oauthFlagsSet() bool {
+	return oauthTokenFlag != "" || oauthTokenCommandFlag != "" || oauthEndpointFlag != "" ||
+		oauthClientIDFlag != "" || oauthClientSecretFlag != "" || oauthScopesFlag != nil
+}
+
+// applySASLFlags merges the SASL flags into cluster.
+func applySASLFlags(cluster *kaf.Cluster) {
+	if cluster.SASL == nil {
+		cluster.SASL = &kaf.SASL{}
+	}
+	if saslMechanismFlag != "" {
+		cluster.SASL.Mechanism = saslMechanismFlag
+	}
+
+	if !oauthFlagsSet() {
+		return
+	}
+	if cluster.SASL.OAuth == nil {
+		cluster.SASL.OAuth = &kaf.OAuth{}
+	}
+	oauth := cluster.SASL.OAuth
+	if oauthTokenFlag != "" || oauthTokenCommandFlag != "" || oauthEndpointFlag != "" {
+		// A token source given on the command line replaces the one of
+		// the config.
+		oauth.Token, oauth.TokenCommand, oauth.TokenEndpoint = oauthTokenFlag, oauthTokenCommandFlag, oauthEndpointFlag
+	}
+	if oauthClientIDFlag != "" {
+		oauth.ClientID = oauthClientIDFlag
+	}
+	if oauthClientSecretFlag != "" {
+		oauth.ClientSecret = oauthClientSecretFlag
+	}
+	if oauthScopesFlag != nil {
+		oauth.Scopes = oauthScopesFlag
+	}
+}
+
+// configureSASL enables the SASL mechanism of cluster in saramaConfig.
+func configureSASL(saramaConfig *sarama.Config, cluster *kaf.Cluster) {
+	saramaConfig.Net.SASL.Enable = true
+	switch strings.ToUpper(cluster.SASL.Mechanism) {
+	case "", sarama.SASLTypePlaintext:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		saramaConfig.Net.SASL.User = cluster.SASL.Username
+		saramaConfig.Net.SASL.Password = cluster.SASL.Password
+	case sarama.SASLTypeOAuth:
+		provider, err := getTokenProvider(cluster.SASL.OAuth)
+		if err != nil {
+			errorExit("Invalid OAUTHBEARER config: %v\n", err)
+		}
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		saramaConfig.Net.SASL.TokenProvider = provider
+	default:
+		errorExit("Unsupported SASL mechanism %v\n", cluster.SASL.Mechanism)
+	}
+}
+
+// tokenRefreshMargin is the time before their expiry at which access tokens
+// are refreshed, so that connections opened by long running commands never
+// authenticate with an expired token.
+const tokenRefreshMargin = time.Minute
+
+// unknownTokenLifetime is the time tokens of unknown expiry are reused.
+const unknownTokenLifetime = 5 * time.Minute
+
+// tokenProvider implements sarama.AccessTokenProvider. Tokens are cached
+// until shortly before they expire.
+type tokenProvider struct {
+	fetch func() (token string, expiry time.Time, err error)
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+}
+
+func (p *tokenProvider) Token() (*sarama.AccessToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == "" || time.Now().After(p.refresh) {
+		token, expiry, err := p.fetch()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get OAuth token: %v", err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("unable to get OAuth token: empty token")
+		}
+		p.token = token
+		if expiry.IsZero() {
+			p.refresh = time.Now().Add(unknownTokenLifetime)
+		} else {
+			p.refresh = expiry.Add(-tokenRefreshMargin)
+		}
+	}
+	return &sarama.AccessToken{Token: p.token}, nil
+}
+
+var (
+	tokenProvidersMu sync.Mutex
+	tokenProviders   = make(map[*kaf.OAuth]*tokenProvider)
+)
+
+// getTokenProvider returns the token provider for oauth. It is shared by all
+// clients of the same cluster, so that tokens are only fetched once. Clusters
+// never share providers, so that tokens are only sent to their own cluster.
+func getTokenProvider(oauth *kaf.OAuth) (*tokenProvider, error) {
+	tokenProvidersMu.Lock()
+	defer tokenProvidersMu.Unlock()
+	if provider, ok := tokenProviders[oauth]; ok {
+		return provider, nil
+	}
+	provider, err := newTokenProvider(oauth)
+	if err != nil {
+		return nil, err
+	}
+	tokenProviders[oauth] = provider
+	return provider, nil
+}
+
+func newTokenProvider(oauth *kaf.OAuth) (*tokenProvider, error) {
+	if oauth == nil {
+		return nil, fmt.Errorf("no token source, set --oauth-token, --oauth-token-command or --oauth-endpoint")
+	}
+
+	sources := 0
+	for _, source := range []string{oauth.Token, oauth.TokenCommand, oauth.TokenEndpoint} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("exactly one of token, token command and token endpoint must be given")
+	}
+
+	switch {
+	case oauth.Token != "":
+		return &tokenProvider{fetch: func() (string, time.Time, error) {
+			return oauth.Token, jwtExpiry(oauth.Token), nil
+		}}, nil
+	case oauth.TokenCommand != "":
+		return &tokenProvider{fetch: func() (string, time.Time, error) {
+			return runTokenCommand(oauth.TokenCommand)
+		}}, nil
+	default:
+		if oauth.ClientID == "" || oauth.ClientSecret == "" {
+			return nil, fmt.Errorf("the token endpoint requires a client ID and secret")
+		}
+		return &tokenProvider{fetch: func() (string, time.Time, error) {
+			return fetchClientCredentialsToken(oauth)
+		}}, nil
+	}
+}
+
+func runTokenCommand(command string) (string, time.Time, error) {
+	out, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", time.Time{}, fmt.Errorf("%v: %v", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", time.Time{}, err
+	}
+	token := strings.TrimSpace(string(out))
+	return token, jwtExpiry(token), nil
+}
+
+// tokenEndpointTimeout limits requests to the OAuth token endpoint.
+const tokenEndpointTimeout = 30 * time.Second
+
+// fetchClientCredentialsToken fetches a token with the client credentials
+// grant of RFC 6749.
+func fetchClientCredentialsToken(oauth *kaf.OAuth) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(oauth.Scopes) > 0 {
+		form.Set("scope", strings.Join(oauth.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, oauth.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(oauth.ClientID), url.QueryEscape(oauth.ClientSecret))
+
+	client := &http.Client{Timeout: tokenEndpointTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("%v: invalid response: %v", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("%v: %v %v", resp.Status, body.Error, body.ErrorDescription)
+	}
+
+	var expiry time.Time
+	if body.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	} else {
+		expiry = jwtExpiry(body.AccessToken)
+	}
+	return body.AccessToken, expiry, nil
+}
+
+// jwtExpiry returns the expiry of token if it is a JWT with an exp claim,
+// otherwise the zero time.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(claims.Exp), 0)
+}
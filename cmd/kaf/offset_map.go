@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var offsetMapFlag string
+
+func init() {
+	consumeCmd.Flags().StringVar(&offsetMapFlag, "offset-map", "", `JSON file mapping partitions to the offset range to consume, e.g. {"0": {"start": 100, "end": 200}, "3": {"start": 42}}. The end offset is exclusive and optional, start defaults to the oldest offset. Partitions not listed are not consumed`)
+}
+
+// offsetRange is the range of a partition to consume with --offset-map.
+type offsetRange struct {
+	Start *int64 `json:"start,omitempty"`
+	End   *int64 `json:"end,omitempty"`
+}
+
+// readOffsetMap reads a --offset-map file.
+func readOffsetMap(path string) (map[int32]offsetRange, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]offsetRange
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no partitions given")
+	}
+
+	offsetMap := make(map[int32]offsetRange, len(raw))
+	for key, r := range raw {
+		partition, err := strconv.ParseInt(key, 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition %q", key)
+		}
+		if r.Start != nil && *r.Start < 0 {
+			return nil, fmt.Errorf("invalid start offset %v of partition %v", *r.Start, partition)
+		}
+		if r.Start != nil && r.End != nil && *r.End < *r.Start {
+			return nil, fmt.Errorf("end offset %v of partition %v is before its start offset %v", *r.End, partition, *r.Start)
+		}
+		offsetMap[int32(partition)] = r
+	}
+	return offsetMap, nil
+}
+
+// consumeOffsetMap consumes the ranges of offsetMap, which are validated
+// against the partitions of topic first.
+func consumeOffsetMap(client sarama.Client, topic string, topicPartitions []int32, offsetMap map[int32]offsetRange, eofIdle time.Duration, emit func(*sarama.ConsumerMessage)) {
+	exists := make(map[int32]bool, len(topicPartitions))
+	for _, partition := range topicPartitions {
+		exists[partition] = true
+	}
+	var partitions []int32
+	for partition := range offsetMap {
+		if !exists[partition] {
+			errorExit("Partition %v of --offset-map does not exist, topic %v has %v partitions\n", partition, topic, len(topicPartitions))
+		}
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	oldestOffsets := getOldestOffsetsFromClient(client, topic, partitions)
+	highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+	starts := make(map[int32]int64, len(partitions))
+	ends := make(map[int32]int64)
+	for _, partition := range partitions {
+		r := offsetMap[partition]
+		start := oldestOffsets[partition]
+		if r.Start != nil {
+			start = *r.Start
+		}
+		if start < oldestOffsets[partition] || start > highWatermarks[partition] {
+			errorExit("Start offset %v of partition %v is out of range, available offsets are %v to %v\n", start, partition, oldestOffsets[partition], highWatermarks[partition])
+		}
+		starts[partition] = start
+
+		if r.End != nil {
+			if *r.End < start {
+				errorExit("End offset %v of partition %v is before its start offset %v\n", *r.End, partition, start)
+			}
+			if *r.End > highWatermarks[partition] {
+				fmt.Fprintf(os.Stderr, "Warning: end offset %v of partition %v is beyond its high watermark %v, waiting for new messages\n", *r.End, partition, highWatermarks[partition])
+			}
+			ends[partition] = *r.End
+		}
+	}
+
+	opts := readOptions{start: starts, end: ends, fair: fair, stop: stopConsume, eofIdle: eofIdle}
+	if err := consumePartitions(client, topic, partitions, opts, emit); err != nil {
+		errorExit("Unable to consume partition: %v\n", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+type bufferedMessage struct {
+	msg      *sarama.ConsumerMessage
+	received time.Time
+}
+
+// messageHeap is a min-heap of messages ordered by their timestamp.
+type messageHeap []*bufferedMessage
+
+func (h messageHeap) Len() int { return len(h) }
+func (h messageHeap) Less(i, j int) bool {
+	return h[i].msg.Timestamp.Before(h[j].msg.Timestamp)
+}
+func (h messageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *messageHeap) Push(x interface{}) {
+	*h = append(*h, x.(*bufferedMessage))
+}
+
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	m := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return m
+}
+
+// timeOrderer buffers messages from multiple partitions and emits them sorted
+// by timestamp. The buffer holds at most size messages, and no message is
+// held longer than window, so the ordering is only approximate across the
+// window.
+type timeOrderer struct {
+	mu     sync.Mutex
+	heap   messageHeap
+	size   int
+	window time.Duration
+	emit   func(*sarama.ConsumerMessage)
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTimeOrderer(size int, window time.Duration, emit func(*sarama.ConsumerMessage)) *timeOrderer {
+	if size < 1 {
+		size = 1
+	}
+	o := &timeOrderer{
+		size:   size,
+		window: window,
+		emit:   emit,
+		done:   make(chan struct{}),
+	}
+
+	o.wg.Add(1)
+	go o.expire()
+	return o
+}
+
+// Add buffers msg, emitting the oldest messages if the buffer is full.
+func (o *timeOrderer) Add(msg *sarama.ConsumerMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	heap.Push(&o.heap, &bufferedMessage{msg: msg, received: time.Now()})
+	for o.heap.Len() > o.size {
+		o.emit(heap.Pop(&o.heap).(*bufferedMessage).msg)
+	}
+}
+
+// Close stops the orderer and emits all remaining buffered messages.
+func (o *timeOrderer) Close() {
+	close(o.done)
+	o.wg.Wait()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for o.heap.Len() > 0 {
+		o.emit(heap.Pop(&o.heap).(*bufferedMessage).msg)
+	}
+}
+
+// expire periodically emits messages which have been buffered longer than
+// the window. Messages with an older timestamp than an expired one are
+// emitted along with it to keep the output ordered.
+func (o *timeOrderer) expire() {
+	defer o.wg.Done()
+
+	interval := o.window / 2
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.done:
+			return
+		case now := <-ticker.C:
+			o.mu.Lock()
+			var newest time.Time
+			for _, m := range o.heap {
+				if now.Sub(m.received) >= o.window && m.msg.Timestamp.After(newest) {
+					newest = m.msg.Timestamp
+				}
+			}
+			if !newest.IsZero() {
+				for o.heap.Len() > 0 && !o.heap[0].msg.Timestamp.After(newest) {
+					o.emit(heap.Pop(&o.heap).(*bufferedMessage).msg)
+				}
+			}
+			o.mu.Unlock()
+		}
+	}
+}
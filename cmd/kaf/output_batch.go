@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	colorable "github.com/mattn/go-colorable"
+)
+
+// batchedOutput buffers the output of consumed messages per partition and
+// writes the buffer of a partition once it holds a batch of messages. This
+// way the lock shared by all partitions is taken once per batch instead of
+// once per message. The output of a partition is never reordered.
+type batchedOutput struct {
+	mu           *sync.Mutex // Synchronizes stderr and stdout.
+	size         int
+	perPartition bool
+	stdout       io.Writer
+
+	batchesMu sync.Mutex
+	batches   map[int32]*outputBatch
+
+	// splitDir is the directory stdout output of each partition is written
+	// to with --output-split-by-partition, in a file of its own. Files are
+	// guarded by mu.
+	splitDir string
+	splitExt string
+	files    map[int32]*splitFile
+}
+
+// splitFile is the output file of a partition.
+type splitFile struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// outputBatch is the buffered output of a partition.
+type outputBatch struct {
+	mu        sync.Mutex
+	partition int32
+	chunks    []outputChunk
+	messages  int
+}
+
+// outputChunk is a piece of output to either stderr or stdout.
+type outputChunk struct {
+	stderr bool
+	data   []byte
+}
+
+var (
+	exitOutputMu sync.Mutex
+	// exitOutput is the output closed by errorExit, if any.
+	exitOutput *batchedOutput
+)
+
+// newBatchedOutput returns an output writing batches of size messages. If
+// perPartition is false, all messages share a single batch, which preserves
+// the order across partitions.
+func newBatchedOutput(mu *sync.Mutex, size int, perPartition bool) *batchedOutput {
+	return &batchedOutput{
+		mu:           mu,
+		size:         size,
+		perPartition: perPartition,
+		stdout:       colorable.NewColorableStdout(),
+		batches:      make(map[int32]*outputBatch),
+	}
+}
+
+// splitByPartition writes the stdout output of each partition to its own
+// file partition-N.ext in dir, which is created if needed.
+func (o *batchedOutput) splitByPartition(dir, ext string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	o.perPartition = true
+	o.splitDir = dir
+	o.splitExt = ext
+	o.files = make(map[int32]*splitFile)
+	return nil
+}
+
+// closeOnExit makes errorExit flush and close o, so that buffered messages
+// are not lost if kaf fails.
+func (o *batchedOutput) closeOnExit() {
+	exitOutputMu.Lock()
+	exitOutput = o
+	exitOutputMu.Unlock()
+}
+
+// closeExitOutput closes the output registered with closeOnExit. It does
+// nothing if there is none.
+func closeExitOutput() {
+	exitOutputMu.Lock()
+	o := exitOutput
+	exitOutput = nil
+	exitOutputMu.Unlock()
+	if o != nil {
+		o.close()
+	}
+}
+
+// fail exits with an error without closing o, since a lock of o may be held.
+func (o *batchedOutput) fail(format string, a ...interface{}) {
+	exitOutputMu.Lock()
+	if exitOutput == o {
+		exitOutput = nil
+	}
+	exitOutputMu.Unlock()
+	errorExit(format, a...)
+}
+
+// stdoutOf returns the writer of the stdout output of partition. The lock mu
+// must be held.
+func (o *batchedOutput) stdoutOf(partition int32) io.Writer {
+	if o.splitDir == "" {
+		return o.stdout
+	}
+	if sf, ok := o.files[partition]; ok {
+		return sf.w
+	}
+	name := filepath.Join(o.splitDir, fmt.Sprintf("partition-%v.%v", partition, o.splitExt))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		o.fail("Unable to open output file: %v\n", err)
+	}
+	sf := &splitFile{f: f, w: bufio.NewWriter(f)}
+	o.files[partition] = sf
+	return sf.w
+}
+
+// write adds the stderr and stdout output of a single message of partition.
+func (o *batchedOutput) write(partition int32, stderr, stdout []byte) {
+	if o.size <= 1 {
+		o.mu.Lock()
+		os.Stderr.Write(stderr)
+		if len(stdout) > 0 {
+			o.stdoutOf(partition).Write(stdout)
+		}
+		o.mu.Unlock()
+		return
+	}
+
+	b := o.batch(partition)
+	b.mu.Lock()
+	b.add(true, stderr)
+	b.add(false, stdout)
+	b.messages++
+	if b.messages >= o.size {
+		o.flushBatch(b)
+	}
+	b.mu.Unlock()
+}
+
+func (o *batchedOutput) batch(partition int32) *outputBatch {
+	if !o.perPartition {
+		partition = 0
+	}
+
+	o.batchesMu.Lock()
+	defer o.batchesMu.Unlock()
+	b, ok := o.batches[partition]
+	if !ok {
+		b = &outputBatch{partition: partition}
+		o.batches[partition] = b
+	}
+	return b
+}
+
+// add appends data to the batch, merging it into the last chunk if that
+// goes to the same stream.
+func (b *outputBatch) add(stderr bool, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if n := len(b.chunks); n > 0 && b.chunks[n-1].stderr == stderr {
+		b.chunks[n-1].data = append(b.chunks[n-1].data, data...)
+		return
+	}
+	b.chunks = append(b.chunks, outputChunk{stderr: stderr, data: append([]byte(nil), data...)})
+}
+
+// flushBatch writes and resets b. The lock of b must be held.
+func (o *batchedOutput) flushBatch(b *outputBatch) {
+	if len(b.chunks) == 0 {
+		return
+	}
+
+	o.mu.Lock()
+	for _, chunk := range b.chunks {
+		if chunk.stderr {
+			os.Stderr.Write(chunk.data)
+		} else {
+			o.stdoutOf(b.partition).Write(chunk.data)
+		}
+	}
+	o.mu.Unlock()
+
+	b.chunks = b.chunks[:0]
+	b.messages = 0
+}
+
+// flush writes all partially filled batches.
+func (o *batchedOutput) flush() {
+	o.batchesMu.Lock()
+	batches := make([]*outputBatch, 0, len(o.batches))
+	for _, b := range o.batches {
+		batches = append(batches, b)
+	}
+	o.batchesMu.Unlock()
+
+	for _, b := range batches {
+		b.mu.Lock()
+		o.flushBatch(b)
+		b.mu.Unlock()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, sf := range o.files {
+		if err := sf.w.Flush(); err != nil {
+			o.fail("Unable to write output file: %v\n", err)
+		}
+	}
+}
+
+// close flushes all output and closes the files of
+// --output-split-by-partition.
+func (o *batchedOutput) close() {
+	o.flush()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for partition, sf := range o.files {
+		if err := sf.f.Close(); err != nil {
+			o.fail("Unable to close output file: %v\n", err)
+		}
+		delete(o.files, partition)
+	}
+}
+
+// flushEvery flushes all batches periodically until stop is closed, so that
+// messages of idle partitions are not held back.
+func (o *batchedOutput) flushEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.flush()
+		case <-stop:
+			return
+		}
+	}
+}
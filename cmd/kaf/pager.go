@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	isatty "github.com/mattn/go-isatty"
+)
+
+// pagerEnvVar holds the pager command. Setting it also enables the pager.
+const pagerEnvVar = "KAF_PAGER"
+
+const defaultPager = "less -FRX"
+
+var pagerFlag bool
+
+var (
+	pagerMu sync.Mutex
+	// pagerDone closes the running pager, if any.
+	pagerDone func()
+)
+
+// startPager pipes everything written to the returned writer through a
+// pager, if enabled via --pager or $KAF_PAGER and stdout is a terminal.
+// Otherwise it returns stdout. done must be called once all output is
+// written; it waits for the user to quit the pager. errorExit calls it as
+// well, so that the pager does not keep running after kaf exited.
+func startPager() (out io.Writer, done func()) {
+	command := os.Getenv(pagerEnvVar)
+	if !pagerFlag && command == "" {
+		return os.Stdout, func() {}
+	}
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return os.Stdout, func() {}
+	}
+
+	if command == "" {
+		command = os.Getenv("PAGER")
+	}
+	if command == "" {
+		command = defaultPager
+	}
+
+	pager := exec.Command("sh", "-c", command)
+	pager.Stdout = os.Stdout
+	pager.Stderr = os.Stderr
+	stdin, err := pager.StdinPipe()
+	if err != nil {
+		return os.Stdout, func() {}
+	}
+	if err := pager.Start(); err != nil {
+		return os.Stdout, func() {}
+	}
+
+	// If the user quits the pager early, writes to the pipe fail with
+	// EPIPE. Since the pipe is neither stdout nor stderr, this does not
+	// raise SIGPIPE and the remaining output is discarded.
+	pagerMu.Lock()
+	pagerDone = func() {
+		stdin.Close()
+		pager.Wait()
+	}
+	pagerMu.Unlock()
+	return stdin, closePager
+}
+
+// closePager closes the pager started by startPager and waits for the user to
+// quit it. It does nothing if no pager runs.
+func closePager() {
+	pagerMu.Lock()
+	done := pagerDone
+	pagerDone = nil
+	pagerMu.Unlock()
+	if done != nil {
+		done()
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/spf13/cobra"
@@ -11,12 +15,52 @@ import (
 
 var keyFlag string
 var numFlag int
+var inputFlag string
+var requiredAcksFlag string
+var retriesFlag int
+var idempotentFlag bool
+var schemaIDFlag int
+var schemaFileFlag string
+var schemaSubjectFlag string
+var checkCompatibilityFlag bool
+var compressionFlag string
+var compressionLevelFlag int
+var headersJSONFlag string
+var timestampFlag string
+
+// timestamp is the timestamp given by --timestamp.
+var timestamp time.Time
+
+// maxFutureTimestamp is how far in the future a timestamp may lie before a
+// warning is printed.
+const maxFutureTimestamp = time.Hour
+
+var warnedFutureTimestamp bool
+
+// headers are the headers given by --headers-json.
+var headers jsonHeaders
+
+// retries counts the retries of the producer, as reported by its backoff
+// function.
+var retries int64
 
 func init() {
 	rootCmd.AddCommand(produceCmd)
 
 	produceCmd.Flags().StringVarP(&keyFlag, "key", "k", "", "Key for the record. Currently only strings are supported.")
 	produceCmd.Flags().IntVarP(&numFlag, "num", "n", 1, "Number of records to send.")
+	produceCmd.Flags().StringVar(&requiredAcksFlag, "required-acks", "all", "Acknowledgements required from the brokers. Possible values: none, leader, all")
+	produceCmd.Flags().IntVar(&retriesFlag, "retries", 3, "Number of times to retry sending a record")
+	produceCmd.Flags().BoolVar(&idempotentFlag, "idempotent", false, "Enable idempotent production. Requires --required-acks all")
+	produceCmd.Flags().IntVar(&schemaIDFlag, "schema-id", 0, "Encode values with the Avro schema of this ID in the schema registry. Values must be JSON")
+	produceCmd.Flags().StringVar(&schemaFileFlag, "schema-file", "", "Register the Avro schema in this file and encode values with it. Values must be JSON")
+	produceCmd.Flags().StringVar(&schemaSubjectFlag, "schema-subject", "", "Subject to register --schema-file under (default TOPIC-value)")
+	produceCmd.Flags().BoolVar(&checkCompatibilityFlag, "check-compatibility", false, "Refuse to register --schema-file if it is not compatible with the latest version of the subject")
+	produceCmd.Flags().StringVar(&compressionFlag, "compression", "none", "Compression codec of record batches. Possible values: none, gzip, snappy, lz4, zstd. zstd requires kafka-version 2.1.0 or later in the cluster config")
+	produceCmd.Flags().IntVar(&compressionLevelFlag, "compression-level", sarama.CompressionLevelDefault, "Compression level of gzip or zstd. Defaults to the default level of the codec")
+	produceCmd.Flags().StringVar(&headersJSONFlag, "headers-json", "", "Headers of the records as JSON object, as printed by consume --headers-as-json, e.g. '{\"k\":\"v\"}'. Binary values are given as {\"b64\": \"...\"}. With --input json, headers of a line take precedence")
+	produceCmd.Flags().StringVar(&timestampFlag, "timestamp", "", "Timestamp of the records in RFC3339 format, e.g. 2019-07-01T12:00:00Z. With --input json, the timestamp of a line takes precedence. Ignored by topics with message.timestamp.type LogAppendTime")
+	produceCmd.Flags().StringVar(&inputFlag, "input", "raw", "Input format. Possible values: raw, json, base64, length-prefixed. With json, each line is a JSON object with the fields key, key_b64, value, value_b64, headers, partition and timestamp, as printed by consume --output json. With base64, each line is a base64 encoded value. With length-prefixed, each value is preceded by its length as 4 byte big endian integer, -1 denoting null.")
 }
 
 var produceCmd = &cobra.Command{
@@ -24,30 +68,428 @@ var produceCmd = &cobra.Command{
 	Short: "Produce record. Reads data from stdin.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		producer, err := sarama.NewSyncProducer(currentCluster.Brokers, getConfig())
+		validateCopyFlags(cmd)
+		switch inputFlag {
+		case "raw", "json":
+			if keyFormatFlag != "none" {
+				errorExit("--key-format requires --input base64 or length-prefixed\n")
+			}
+		case "base64", "length-prefixed":
+			if keyFormatFlag != "none" && keyFormatFlag != "framed" {
+				errorExit("Invalid value for --key-format: %v\n", keyFormatFlag)
+			}
+		default:
+			errorExit("Invalid input format %v\n", inputFlag)
+		}
+		if interactiveFlag && inputFlag != "raw" {
+			errorExit("--interactive can not be combined with --input %v\n", inputFlag)
+		}
+		parseKeyFromValue()
+
+		if headersJSONFlag != "" {
+			var err error
+			headers, err = parseJSONHeaders(headersJSONFlag)
+			if err != nil {
+				errorExit("Invalid value for --headers-json: %v\n", err)
+			}
+		}
+
+		cfg := getConfig()
+		cfg.Producer.Partitioner = newExplicitPartitioner
+		if err := applyProducerFlags(cfg); err != nil {
+			errorExit("%v\n", err)
+		}
+		if headersJSONFlag != "" {
+			if err := requireFeature(cfg, featureHeaders); err != nil {
+				errorExit("--headers-json: %v\n", err)
+			}
+		}
+		if timestampFlag != "" {
+			// Older message formats have no timestamp, so it would be
+			// silently dropped.
+			if err := requireFeature(cfg, featureTimestamps); err != nil {
+				errorExit("--timestamp: %v\n", err)
+			}
+			var err error
+			timestamp, err = parseTimestamp(timestampFlag)
+			if err != nil {
+				errorExit("Invalid value for --timestamp: %v\n", err)
+			}
+		}
+		openFailuresFile()
+		defer finishProduceReport()
+		defer reportRetries()
+		defer reportCompressionRatio(cfg)
+		defer reportMissingKeys()
+
+		client := getClientFromConfig(cfg)
+		checkTopicExists(client, args[0])
+
+		resolveValueSchema(args[0])
+		resolveMaxMessageBytes(cfg, args[0])
+
+		producer, err := sarama.NewSyncProducerFromClient(client)
 		if err != nil {
 			errorExit("Unable to create new sync producer: %v\n", err)
 		}
 
+		if copyFromTopicFlag != "" {
+			produceCopy(client, producer, args[0])
+			return
+		}
+		if interactiveFlag {
+			produceInteractive(producer, args[0])
+			return
+		}
+
+		switch inputFlag {
+		case "json":
+			produceJSON(producer, args[0])
+			return
+		case "base64", "length-prefixed":
+			produceFramed(producer, args[0])
+			return
+		}
+
 		data, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			errorExit("Unable to read data\n")
 		}
 
-		for i := 0; i < numFlag; i++ {
-			partition, offset, err := producer.SendMessage(&sarama.ProducerMessage{
-				Topic: args[0],
-				Key:   sarama.StringEncoder(keyFlag),
-				Value: sarama.ByteEncoder(data),
-			})
+		var key sarama.Encoder = sarama.StringEncoder(keyFlag)
+		if keyPath != nil {
+			derived, err := keyFromValue(data)
+			if skipMissingKey(err) {
+				return
+			}
 			if err != nil {
-				fmt.Printf("Failed to send record: %v.", err)
-				os.Exit(1)
+				errorExit("Unable to derive key: %v\n", err)
 			}
+			key = sarama.ByteEncoder(derived)
+		}
 
-			fmt.Printf("Sent record to partition %v at offset %v.\n", partition, offset)
+		data, err = encodeValue(data)
+		if err != nil {
+			errorExit("Unable to encode value: %v\n", err)
+		}
 
+		for i := 0; i < numFlag; i++ {
+			sendMessage(producer, &sarama.ProducerMessage{
+				Topic:     args[0],
+				Key:       key,
+				Value:     sarama.ByteEncoder(data),
+				Headers:   headers.recordHeaders(),
+				Timestamp: timestamp,
+			})
 		}
 
 	},
 }
+
+// applyProducerFlags configures delivery guarantees of the producer.
+func applyProducerFlags(cfg *sarama.Config) error {
+	switch requiredAcksFlag {
+	case "none":
+		cfg.Producer.RequiredAcks = sarama.NoResponse
+	case "leader":
+		cfg.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all":
+		cfg.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return fmt.Errorf("Invalid value for --required-acks: %v", requiredAcksFlag)
+	}
+
+	if retriesFlag < 0 {
+		return fmt.Errorf("Invalid value for --retries: %v", retriesFlag)
+	}
+	cfg.Producer.Retry.Max = retriesFlag
+	backoff := cfg.Producer.Retry.Backoff
+	cfg.Producer.Retry.BackoffFunc = func(_, _ int) time.Duration {
+		atomic.AddInt64(&retries, 1)
+		return backoff
+	}
+
+	switch compressionFlag {
+	case "none":
+		cfg.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		cfg.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		cfg.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		cfg.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		if err := requireFeature(cfg, featureZstd); err != nil {
+			return err
+		}
+		cfg.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return fmt.Errorf("Invalid value for --compression: %v", compressionFlag)
+	}
+	if compressionLevelFlag != sarama.CompressionLevelDefault {
+		if compressionFlag != "gzip" && compressionFlag != "zstd" {
+			return fmt.Errorf("--compression-level is only supported by gzip and zstd")
+		}
+		cfg.Producer.CompressionLevel = compressionLevelFlag
+	}
+
+	if idempotentFlag {
+		if cfg.Producer.RequiredAcks != sarama.WaitForAll {
+			return fmt.Errorf("--idempotent requires --required-acks all")
+		}
+		if cfg.Producer.Retry.Max < 1 {
+			return fmt.Errorf("--idempotent requires --retries of at least 1")
+		}
+		cfg.Producer.Idempotent = true
+		cfg.Net.MaxOpenRequests = 1
+	}
+	return nil
+}
+
+func reportRetries() {
+	if n := atomic.LoadInt64(&retries); n > 0 {
+		printNotice("Retried sending records %v times.\n", n)
+	}
+}
+
+// reportCompressionRatio prints the mean compression ratio of all sent
+// record batches, as recorded by sarama.
+func reportCompressionRatio(cfg *sarama.Config) {
+	if cfg.Producer.Compression == sarama.CompressionNone {
+		return
+	}
+	// The histogram records the ratio times 100.
+	ratio, ok := cfg.MetricRegistry.Get("compression-ratio").(interface {
+		Count() int64
+		Mean() float64
+	})
+	if ok && ratio.Count() > 0 {
+		printNotice("Compression ratio: %.2f\n", ratio.Mean()/100)
+	}
+}
+
+// resolveValueSchema determines the ID of the Avro schema values are encoded
+// with, registering --schema-file if given.
+func resolveValueSchema(topic string) {
+	if schemaIDFlag == 0 && schemaFileFlag == "" {
+		if checkCompatibilityFlag {
+			errorExit("--check-compatibility requires --schema-file\n")
+		}
+		return
+	}
+	if schemaIDFlag != 0 && schemaFileFlag != "" {
+		errorExit("Only one of --schema-id and --schema-file may be set\n")
+	}
+
+	schemaCache = getSchemaCache()
+	if schemaCache == nil {
+		errorExit("Encoding Avro requires a schema registry\n")
+	}
+	if schemaFileFlag == "" {
+		return
+	}
+
+	schema, err := ioutil.ReadFile(schemaFileFlag)
+	if err != nil {
+		errorExit("Unable to read schema: %v\n", err)
+	}
+	subject := schemaSubjectFlag
+	if subject == "" {
+		subject = topic + "-value"
+	}
+
+	if checkCompatibilityFlag {
+		compatible, err := schemaCache.CheckCompatibility(subject, string(schema))
+		if err != nil {
+			errorExit("Unable to check schema compatibility: %v\n", err)
+		}
+		if !compatible {
+			errorExit("Schema is not compatible with the latest version of subject %v\n", subject)
+		}
+	}
+
+	schemaIDFlag, err = schemaCache.RegisterSchema(subject, string(schema))
+	if err != nil {
+		errorExit("Unable to register schema: %v\n", err)
+	}
+}
+
+// encodeValue encodes a JSON value with the Avro schema selected via
+// --schema-id or --schema-file. Values are returned unchanged if no schema
+// was selected. Null values are never encoded.
+func encodeValue(value []byte) ([]byte, error) {
+	if schemaIDFlag == 0 || value == nil {
+		return value, nil
+	}
+	return schemaCache.EncodeMessage(schemaIDFlag, value)
+}
+
+// produceJSON sends one record per line of stdin, each line holding a
+// jsonMessage.
+func produceJSON(producer sarama.SyncProducer, topic string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		msg, err := parseJSONLine(scanner.Bytes(), topic)
+		if skipMissingKey(err) {
+			continue
+		}
+		if err != nil {
+			errorExit("Invalid input on line %v: %v\n", line, err)
+		}
+		sendMessage(producer, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		errorExit("Unable to read data on line %v: %v\n", line+1, err)
+	}
+}
+
+// maxInputLineSize limits the size of a single line of line based input.
+const maxInputLineSize = 64 * 1024 * 1024
+
+func parseJSONLine(line []byte, topic string) (*sarama.ProducerMessage, error) {
+	var m jsonMessage
+	if err := json.Unmarshal(line, &m); err != nil {
+		return nil, err
+	}
+
+	key, err := m.key()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil && keyFlag != "" {
+		key = []byte(keyFlag)
+	}
+	value, err := m.value()
+	if err != nil {
+		return nil, err
+	}
+	if keyPath != nil {
+		if key, err = keyFromValue(value); err != nil {
+			return nil, err
+		}
+	}
+	value, err = encodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	lineHeaders := make(jsonHeaders, len(headers)+len(m.Headers))
+	for k, v := range headers {
+		lineHeaders[k] = v
+	}
+	for k, v := range m.Headers {
+		lineHeaders[k] = v
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Headers:   lineHeaders.recordHeaders(),
+		Timestamp: timestamp,
+	}
+	if m.Timestamp != nil {
+		msg.Timestamp = *m.Timestamp
+	}
+	if key != nil {
+		msg.Key = sarama.ByteEncoder(key)
+	}
+	if value != nil {
+		msg.Value = sarama.ByteEncoder(value)
+	}
+	if m.Partition != nil {
+		msg.Partition = *m.Partition
+		msg.Metadata = explicitPartition{}
+	}
+	return msg, nil
+}
+
+func sendMessage(producer sarama.SyncProducer, msg *sarama.ProducerMessage) {
+	warnFutureTimestamp(msg.Timestamp)
+
+	msgs, err := checkMessageSize(msg)
+	if err != nil {
+		failedRecords++
+		if recordFailure(msg, err) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
+		}
+		printAcksReport()
+		errorExit("%v\n", err)
+	}
+	for i, msg := range msgs {
+		partition, ok := sendRecord(producer, msg)
+		if !ok {
+			// The value can not be reassembled without this chunk.
+			return
+		}
+		if i == 0 && len(msgs) > 1 {
+			// Keyless chunks would be spread across partitions.
+			for _, chunk := range msgs[1:] {
+				chunk.Partition = partition
+				chunk.Metadata = explicitPartition{}
+			}
+		}
+	}
+}
+
+// sendRecord sends msg and returns its partition, or false if sending failed
+// and the failure was recorded.
+func sendRecord(producer sarama.SyncProducer, msg *sarama.ProducerMessage) (int32, bool) {
+	partition, offset, err := producer.SendMessage(msg)
+	if err != nil {
+		failedRecords++
+		if recordFailure(msg, err) {
+			fmt.Fprintf(os.Stderr, "Failed to send record: %v.\n", err)
+			return 0, false
+		}
+		fmt.Printf("Failed to send record: %v.", err)
+		reportRetries()
+		printAcksReport()
+		os.Exit(1)
+	}
+	succeededRecords++
+
+	printInfo("Sent record to partition %v at offset %v.\n", partition, offset)
+	return partition, true
+}
+
+// warnFutureTimestamp prints a warning once if t lies suspiciously far in the
+// future, e.g. because seconds were mistaken for milliseconds.
+func warnFutureTimestamp(t time.Time) {
+	if warnedFutureTimestamp || !t.After(time.Now().Add(maxFutureTimestamp)) {
+		return
+	}
+	warnedFutureTimestamp = true
+	fmt.Fprintf(os.Stderr, "Warning: record timestamp %v is in the future\n", t.Format(time.RFC3339))
+}
+
+// explicitPartition marks a message whose partition was chosen by the user.
+type explicitPartition struct{}
+
+// explicitPartitioner sends messages marked with explicitPartition to their
+// given partition and hashes the key of all others.
+type explicitPartitioner struct {
+	hash sarama.Partitioner
+}
+
+func newExplicitPartitioner(topic string) sarama.Partitioner {
+	return &explicitPartitioner{hash: sarama.NewHashPartitioner(topic)}
+}
+
+func (p *explicitPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if _, ok := msg.Metadata.(explicitPartition); ok {
+		return msg.Partition, nil
+	}
+	return p.hash.Partition(msg, numPartitions)
+}
+
+func (p *explicitPartitioner) RequiresConsistency() bool {
+	return true
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+
+	"github.com/birdayz/kaf/avro"
+)
+
+var (
+	copyFromClusterFlag string
+	copyFromTopicFlag   string
+	copyFromTimeFlag    string
+	copyLimitFlag       int64
+	translateSchemaFlag bool
+)
+
+func init() {
+	produceCmd.Flags().StringVar(&copyFromClusterFlag, "from-cluster", "", "Name of the configured cluster to copy --from-topic from (default current cluster)")
+	produceCmd.Flags().StringVar(&copyFromTopicFlag, "from-topic", "", "Copy the records of this topic to TOPIC instead of reading stdin, keeping keys, headers and timestamps. Records are copied up to the newest offset at startup. Partitions are kept if both topics have the same number of partitions, otherwise records are partitioned by key")
+	produceCmd.Flags().StringVar(&copyFromTimeFlag, "from-time", "", "Only copy records of --from-topic produced at or after this time in RFC3339 format, e.g. 2019-07-01T12:00:00Z")
+	produceCmd.Flags().Int64Var(&copyLimitFlag, "limit", 0, "Stop after copying this many records of --from-topic. 0 means no limit")
+	produceCmd.Flags().BoolVar(&translateSchemaFlag, "translate-schema", false, "Register the Avro schemas of keys and values copied with --from-topic in the schema registry of the current cluster and rewrite their schema IDs. Requires schema registries for both clusters")
+}
+
+// copyOnlyFlags may only be given with --from-topic, inputFlags not with it.
+var (
+	copyOnlyFlags = []string{"from-cluster", "from-time", "limit", "translate-schema"}
+	inputFlags    = []string{"key", "num", "input", "interactive", "schema-id", "schema-file", "headers-json", "timestamp", "key-format", "chunk", "key-from-value", "missing-key"}
+)
+
+// validateCopyFlags rejects flags which do not apply to the selected input,
+// stdin or --from-topic.
+func validateCopyFlags(cmd *cobra.Command) {
+	if copyFromTopicFlag == "" {
+		for _, name := range copyOnlyFlags {
+			if cmd.Flags().Changed(name) {
+				errorExit("--%v requires --from-topic\n", name)
+			}
+		}
+		return
+	}
+	for _, name := range inputFlags {
+		if cmd.Flags().Changed(name) {
+			errorExit("--%v can not be combined with --from-topic\n", name)
+		}
+	}
+	if copyLimitFlag < 0 {
+		errorExit("--limit must not be negative\n")
+	}
+}
+
+// schemaTranslation is the result of translating a schema ID of the source
+// registry to the target registry.
+type schemaTranslation struct {
+	id       int
+	err      error
+	failures int64
+}
+
+// schemaTranslator rewrites the schema IDs of Avro-encoded data from the
+// registry of the source cluster to the registry of the target cluster,
+// registering schemas in the target registry as needed.
+type schemaTranslator struct {
+	src, dst *avro.SchemaCache
+	topic    string
+
+	mu           sync.Mutex
+	translations map[string]*schemaTranslation
+}
+
+func newSchemaTranslator(src, dst *avro.SchemaCache, topic string) *schemaTranslator {
+	return &schemaTranslator{
+		src:          src,
+		dst:          dst,
+		topic:        topic,
+		translations: make(map[string]*schemaTranslation),
+	}
+}
+
+// translate returns b with the schema ID of the target registry, registering
+// the schema under the subject TOPIC-<part> of the target topic, where part is
+// key or value. Data without schema registry header is returned unchanged.
+func (t *schemaTranslator) translate(b []byte, part string) ([]byte, error) {
+	if len(b) < 5 || b[0] != 0x00 {
+		return b, nil
+	}
+	srcID := int(binary.BigEndian.Uint32(b[1:5]))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	name := fmt.Sprintf("%v %v", part, srcID)
+	tr, ok := t.translations[name]
+	if !ok {
+		tr = &schemaTranslation{}
+		var schema string
+		schema, tr.err = t.src.SchemaByID(srcID)
+		if tr.err == nil {
+			tr.id, tr.err = t.dst.RegisterSchema(t.topic+"-"+part, schema)
+		}
+		t.translations[name] = tr
+	}
+	if tr.err != nil {
+		tr.failures++
+		return nil, fmt.Errorf("unable to translate schema %v of %v: %v", srcID, part, tr.err)
+	}
+
+	translated := make([]byte, len(b))
+	copy(translated, b)
+	binary.BigEndian.PutUint32(translated[1:5], uint32(tr.id))
+	return translated, nil
+}
+
+// report prints the schemas which could not be translated and returns the
+// number of records skipped because of them.
+func (t *schemaTranslator) report() (failures int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.translations))
+	for name := range t.translations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tr := t.translations[name]
+		if tr.err == nil {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Unable to translate schema of %v: %v (%v records skipped)\n", name, tr.err, tr.failures)
+		failures += tr.failures
+	}
+	return failures
+}
+
+// produceCopy copies the records of --from-topic to topic and prints the
+// number of records copied from each partition.
+func produceCopy(client sarama.Client, producer sarama.SyncProducer, topic string) {
+	cluster := currentCluster
+	if copyFromClusterFlag != "" {
+		cluster = config.Cluster(copyFromClusterFlag)
+		if cluster == nil {
+			errorExit("Could not find cluster with name %v\n", copyFromClusterFlag)
+		}
+	}
+	if copyFromTopicFlag == topic && cluster == currentCluster {
+		errorExit("Refusing to copy topic %v onto itself\n", topic)
+	}
+
+	var translator *schemaTranslator
+	if translateSchemaFlag {
+		src, dst := getSchemaCacheOf(cluster), getSchemaCache()
+		if src == nil || dst == nil {
+			errorExit("--translate-schema requires schema registries of both clusters\n")
+		}
+		translator = newSchemaTranslator(src, dst, topic)
+	}
+
+	srcClient := getClientOf(cluster)
+	checkTopicExists(srcClient, copyFromTopicFlag)
+	partitions, err := srcClient.Partitions(copyFromTopicFlag)
+	if err != nil {
+		errorExit("Unable to get partitions: %v\n", err)
+	}
+	dstPartitions, err := client.Partitions(topic)
+	if err != nil {
+		errorExit("Unable to get partitions: %v\n", err)
+	}
+	keepPartitions := len(partitions) == len(dstPartitions)
+
+	opts := readOptions{end: getHighWatermarksFromClient(srcClient, copyFromTopicFlag, partitions)}
+	if copyFromTimeFlag != "" {
+		t, err := parseTimestamp(copyFromTimeFlag)
+		if err != nil {
+			errorExit("Invalid value for --from-time: %v\n", err)
+		}
+		opts.start = getOffsetsFromClient(srcClient, copyFromTopicFlag, partitions, timeToMillis(t))
+		for partition, offset := range opts.start {
+			if offset < 0 {
+				// No record was produced after t.
+				opts.start[partition] = opts.end[partition]
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopCopy := func() { stopOnce.Do(func() { close(stop) }) }
+	opts.stop = stop
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		stopCopy()
+	}()
+
+	var (
+		mu     sync.Mutex
+		taken  int64
+		copied = make(map[int32]int64)
+	)
+	emit := func(msg *sarama.ConsumerMessage) {
+		mu.Lock()
+		if copyLimitFlag > 0 && taken >= copyLimitFlag {
+			mu.Unlock()
+			return
+		}
+		taken++
+		if taken == copyLimitFlag {
+			stopCopy()
+		}
+		mu.Unlock()
+
+		pm := &sarama.ProducerMessage{Topic: topic, Timestamp: msg.Timestamp}
+		for _, h := range msg.Headers {
+			pm.Headers = append(pm.Headers, *h)
+		}
+		if keepPartitions {
+			pm.Partition = msg.Partition
+			pm.Metadata = explicitPartition{}
+		}
+		key, value := msg.Key, msg.Value
+		var err error
+		if translator != nil {
+			if key, err = translator.translate(key, "key"); err == nil {
+				value, err = translator.translate(value, "value")
+			}
+		}
+		if key != nil {
+			pm.Key = sarama.ByteEncoder(key)
+		}
+		if value != nil {
+			pm.Value = sarama.ByteEncoder(value)
+		}
+		if err != nil {
+			// Reported by the translator once copying finished.
+			mu.Lock()
+			defer mu.Unlock()
+			failedRecords++
+			recordFailure(pm, err)
+			return
+		}
+
+		_, _, err = producer.SendMessage(pm)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failedRecords++
+			if recordFailure(pm, err) {
+				fmt.Fprintf(os.Stderr, "Failed to copy record of partition %v at offset %v: %v\n", msg.Partition, msg.Offset, err)
+				return
+			}
+			printAcksReport()
+			errorExit("Failed to copy record of partition %v at offset %v: %v\n", msg.Partition, msg.Offset, err)
+		}
+		succeededRecords++
+		copied[msg.Partition]++
+	}
+	if err := consumePartitions(srcClient, copyFromTopicFlag, partitions, opts, emit); err != nil {
+		errorExit("Unable to consume %v: %v\n", copyFromTopicFlag, err)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "PARTITION\tCOPIED\t\n")
+	for _, partition := range partitions {
+		fmt.Fprintf(w, "%v\t%v\t\n", partition, copied[partition])
+	}
+	w.Flush()
+
+	if translator != nil {
+		if failures := translator.report(); failures > 0 && failuresFile == nil {
+			printAcksReport()
+			errorExit("%v records were not copied because their schemas could not be translated\n", failures)
+		}
+	}
+}
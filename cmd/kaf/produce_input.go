@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+var keyFormatFlag string
+
+func init() {
+	produceCmd.Flags().StringVar(&keyFormatFlag, "key-format", "none", "How keys are read with --input base64 or length-prefixed. Possible values: none (use --key), framed (each value is preceded by its key in the same framing)")
+}
+
+// frameReader reads the records of a framed input one by one.
+type frameReader interface {
+	// next returns the next frame, or io.EOF at the end of the input.
+	next() ([]byte, error)
+	// position describes the position of the last frame for error
+	// messages.
+	position() string
+}
+
+// base64Reader reads one base64 encoded frame per line.
+type base64Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newBase64Reader(r io.Reader) *base64Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineSize)
+	return &base64Reader{scanner: scanner}
+}
+
+func (r *base64Reader) next() ([]byte, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			r.line++
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	r.line++
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(r.scanner.Text()))
+}
+
+func (r *base64Reader) position() string {
+	return fmt.Sprintf("line %v", r.line)
+}
+
+// lengthPrefixedReader reads frames preceded by their length as 4 byte big
+// endian integer. A length of -1 denotes null.
+type lengthPrefixedReader struct {
+	r     *bufio.Reader
+	frame int
+}
+
+func (r *lengthPrefixedReader) next() ([]byte, error) {
+	r.frame++
+	var length int32
+	if err := binary.Read(r.r, binary.BigEndian, &length); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated length")
+		}
+		return nil, err
+	}
+	switch {
+	case length == -1:
+		return nil, nil
+	case length < -1 || length > maxInputLineSize:
+		return nil, fmt.Errorf("invalid length %v", length)
+	}
+
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, fmt.Errorf("truncated frame of length %v: %v", length, err)
+	}
+	return b, nil
+}
+
+func (r *lengthPrefixedReader) position() string {
+	return fmt.Sprintf("frame %v", r.frame)
+}
+
+// produceFramed sends one record per frame of stdin, or per two frames if
+// keys are framed as well.
+func produceFramed(producer sarama.SyncProducer, topic string) {
+	var r frameReader
+	switch inputFlag {
+	case "base64":
+		r = newBase64Reader(os.Stdin)
+	case "length-prefixed":
+		r = &lengthPrefixedReader{r: bufio.NewReader(os.Stdin)}
+	}
+
+	for {
+		var key []byte
+		if keyFormatFlag == "framed" {
+			var err error
+			key, err = r.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errorExit("Invalid input at %v: %v\n", r.position(), err)
+			}
+		} else if keyFlag != "" {
+			key = []byte(keyFlag)
+		}
+
+		value, err := r.next()
+		if err == io.EOF {
+			if keyFormatFlag == "framed" {
+				errorExit("Invalid input at %v: key without value\n", r.position())
+			}
+			return
+		}
+		if err != nil {
+			errorExit("Invalid input at %v: %v\n", r.position(), err)
+		}
+		if keyPath != nil {
+			key, err = keyFromValue(value)
+			if skipMissingKey(err) {
+				continue
+			}
+			if err != nil {
+				errorExit("Invalid input at %v: %v\n", r.position(), err)
+			}
+		}
+		value, err = encodeValue(value)
+		if err != nil {
+			errorExit("Unable to encode value at %v: %v\n", r.position(), err)
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic:     topic,
+			Headers:   headers.recordHeaders(),
+			Timestamp: timestamp,
+		}
+		if key != nil {
+			msg.Key = sarama.ByteEncoder(key)
+		}
+		if value != nil {
+			msg.Value = sarama.ByteEncoder(value)
+		}
+		sendMessage(producer, msg)
+	}
+}
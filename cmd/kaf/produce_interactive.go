@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/chzyer/readline"
+	isatty "github.com/mattn/go-isatty"
+)
+
+var interactiveFlag bool
+
+func init() {
+	produceCmd.Flags().BoolVar(&interactiveFlag, "interactive", false, "Send one record per line of stdin until EOF. A line may start with headers as @name=value, followed by KEY::VALUE or only a value, e.g. '@trace=1 user-1::{\"a\":1}'")
+}
+
+// parseInteractiveLine parses a line of produce --interactive into a record.
+// Headers of the line are added to the headers given by --headers-json.
+func parseInteractiveLine(line, topic string) (*sarama.ProducerMessage, error) {
+	lineHeaders := make(jsonHeaders, len(headers))
+	for k, v := range headers {
+		lineHeaders[k] = v
+	}
+	for strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+		header := line[1:end]
+		line = strings.TrimLeft(line[end:], " ")
+
+		eq := strings.IndexByte(header, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid header %q, expected @name=value", header)
+		}
+		lineHeaders[header[:eq]] = jsonHeaderValue(header[eq+1:])
+	}
+
+	key := keyFlag
+	value := line
+	if sep := strings.Index(line, "::"); sep >= 0 {
+		key, value = line[:sep], line[sep+2:]
+	}
+	if keyPath != nil {
+		derived, err := keyFromValue([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+		key = string(derived)
+	}
+
+	encoded, err := encodeValue([]byte(value))
+	if err != nil {
+		return nil, err
+	}
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(encoded),
+		Headers:   lineHeaders.recordHeaders(),
+		Timestamp: timestamp,
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	return msg, nil
+}
+
+// produceInteractive sends one record per line of stdin. Invalid lines are
+// reported and skipped. If stdin is a terminal, lines are read with a prompt,
+// line editing and history.
+func produceInteractive(producer sarama.SyncProducer, topic string) {
+	readLine, done := stdinLineReader()
+	defer done()
+
+	for {
+		line, ok := readLine()
+		if !ok {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		msg, err := parseInteractiveLine(line, topic)
+		if skipMissingKey(err) {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid line: %v\n", err)
+			continue
+		}
+		sendMessage(producer, msg)
+	}
+}
+
+// stdinLineReader returns a function returning the next line of stdin, or
+// false at EOF, and a function to call once done. Terminals are read with
+// readline, pipes with a scanner.
+func stdinLineReader() (readLine func() (string, bool), done func()) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineSize)
+		readLine = func() (string, bool) {
+			if scanner.Scan() {
+				return scanner.Text(), true
+			}
+			if err := scanner.Err(); err != nil {
+				errorExit("Unable to read data: %v\n", err)
+			}
+			return "", false
+		}
+		return readLine, func() {}
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt: "> ",
+		Stdout: os.Stderr,
+	})
+	if err != nil {
+		errorExit("Unable to read from terminal: %v\n", err)
+	}
+	readLine = func() (string, bool) {
+		for {
+			line, err := rl.Readline()
+			switch {
+			case err == readline.ErrInterrupt && line != "":
+				// Ctrl-C discards the current line.
+				continue
+			case err != nil:
+				// Ctrl-D or Ctrl-C on an empty line.
+				return "", false
+			}
+			return line, true
+		}
+	}
+	return readLine, func() { rl.Close() }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	keyFromValueFlag string
+	missingKeyFlag   string
+
+	keyPath            jsonPath
+	skippedMissingKeys int64
+)
+
+func init() {
+	produceCmd.Flags().StringVar(&keyFromValueFlag, "key-from-value", "", "Use the element of JSON values at this JSONPath as key, e.g. '$.user.id'. Takes precedence over keys of --input json. Records are partitioned by the derived key")
+	produceCmd.Flags().StringVar(&missingKeyFlag, "missing-key", "error", "What to do with values without the element of --key-from-value or which are not JSON. Possible values: error, skip (do not send the record)")
+}
+
+// errMissingKey is returned by keyFromValue if the value has no key.
+var errMissingKey = errors.New("value is not JSON or has no element at --key-from-value")
+
+// parseKeyFromValue validates --key-from-value and --missing-key.
+func parseKeyFromValue() {
+	if missingKeyFlag != "error" && missingKeyFlag != "skip" {
+		errorExit("Invalid value for --missing-key: %v\n", missingKeyFlag)
+	}
+	if keyFromValueFlag == "" {
+		return
+	}
+	if keyFlag != "" || keyFormatFlag == "framed" {
+		errorExit("--key-from-value can not be combined with --key or --key-format framed\n")
+	}
+	path, err := parseJSONPath(keyFromValueFlag)
+	if err != nil {
+		errorExit("Invalid value for --key-from-value: %v\n", err)
+	}
+	keyPath = path
+}
+
+// keyFromValue returns the key at --key-from-value of the JSON value, which
+// must not be Avro encoded yet.
+func keyFromValue(value []byte) ([]byte, error) {
+	key, ok := keyPath.extract(value)
+	if !ok {
+		return nil, errMissingKey
+	}
+	return []byte(key), nil
+}
+
+// skipMissingKey returns true if err is errMissingKey and records without key
+// are skipped with --missing-key skip.
+func skipMissingKey(err error) bool {
+	if err != errMissingKey || missingKeyFlag != "skip" {
+		return false
+	}
+	skippedMissingKeys++
+	return true
+}
+
+// reportMissingKeys prints the number of records skipped with --missing-key
+// skip.
+func reportMissingKeys() {
+	if skippedMissingKeys > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %v records without key at --key-from-value.\n", skippedMissingKeys)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	acksReportFlag   bool
+	failuresFileFlag string
+
+	failuresFile     *os.File
+	succeededRecords int64
+	failedRecords    int64
+)
+
+func init() {
+	produceCmd.Flags().BoolVar(&acksReportFlag, "acks-report", false, "Print the number of sent, succeeded and failed records once producing finished")
+	produceCmd.Flags().StringVar(&failuresFileFlag, "failures-file", "", "Keep producing if a record fails and append it to this file, one JSON object per line in the format of --input json with an additional error field. Exits with code 1 if any record failed")
+}
+
+// failedRecord is a line of --failures-file.
+type failedRecord struct {
+	jsonMessage
+	Error string `json:"error"`
+}
+
+// newFailedRecord builds the --failures-file line of msg. Key and value are
+// written as sent, i.e. after Avro encoding.
+func newFailedRecord(msg *sarama.ProducerMessage, sendErr error) (*failedRecord, error) {
+	cm := &sarama.ConsumerMessage{Timestamp: msg.Timestamp}
+	for i := range msg.Headers {
+		cm.Headers = append(cm.Headers, &msg.Headers[i])
+	}
+	var err error
+	if msg.Key != nil {
+		if cm.Key, err = msg.Key.Encode(); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Value != nil {
+		if cm.Value, err = msg.Value.Encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &failedRecord{jsonMessage: newJSONMessage(cm, cm.Key, cm.Value), Error: sendErr.Error()}
+	r.Offset = nil
+	r.Partition = nil
+	if _, ok := msg.Metadata.(explicitPartition); ok {
+		r.Partition = &msg.Partition
+	}
+	if msg.Timestamp.IsZero() {
+		r.Timestamp = nil
+	}
+	return r, nil
+}
+
+// openFailuresFile opens --failures-file for appending, if set.
+func openFailuresFile() {
+	if failuresFileFlag == "" {
+		return
+	}
+	var err error
+	failuresFile, err = os.OpenFile(failuresFileFlag, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		errorExit("Unable to open failures file: %v\n", err)
+	}
+}
+
+// recordFailure appends msg to --failures-file. It returns false if no
+// failures file is used.
+func recordFailure(msg *sarama.ProducerMessage, sendErr error) bool {
+	if failuresFile == nil {
+		return false
+	}
+	r, err := newFailedRecord(msg, sendErr)
+	if err != nil {
+		errorExit("Unable to encode failed record: %v\n", err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		errorExit("Unable to encode failed record: %v\n", err)
+	}
+	if _, err := failuresFile.Write(append(b, '\n')); err != nil {
+		errorExit("Unable to write failures file: %v\n", err)
+	}
+	return true
+}
+
+// printAcksReport prints the totals of --acks-report.
+func printAcksReport() {
+	if !acksReportFlag {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Sent %v records: %v succeeded, %v failed.\n", succeededRecords+failedRecords, succeededRecords, failedRecords)
+}
+
+// finishProduceReport prints the report, closes --failures-file and exits
+// with code 1 if any record failed.
+func finishProduceReport() {
+	printAcksReport()
+	if failuresFile == nil {
+		return
+	}
+	if err := failuresFile.Close(); err != nil {
+		errorExit("Unable to write failures file: %v\n", err)
+	}
+	if failedRecords > 0 {
+		errorExit("%v records failed, see %v\n", failedRecords, failuresFileFlag)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	requestSizeLimitFlag int
+	chunkFlag            bool
+)
+
+// maxMessageBytes is the size limit of a single record, 0 if unknown.
+var maxMessageBytes int
+
+// recordOverhead is an upper bound of the bytes a record batch adds to the
+// key, value and headers of a single record.
+const recordOverhead = 128
+
+// Headers added to each chunk of a value split with --chunk.
+const (
+	chunkIDHeader    = "kaf.chunk.id"
+	chunkIndexHeader = "kaf.chunk.index"
+	chunkCountHeader = "kaf.chunk.count"
+)
+
+func init() {
+	produceCmd.Flags().IntVar(&requestSizeLimitFlag, "request-size-limit", 0, "Maximum size of a record in bytes. Defaults to max.message.bytes of the topic")
+	produceCmd.Flags().BoolVar(&chunkFlag, "chunk", false, "Split values exceeding the size limit into multiple records with the same key. Chunks carry the headers "+chunkIDHeader+", "+chunkIndexHeader+" and "+chunkCountHeader)
+}
+
+// resolveMaxMessageBytes determines the size limit of records sent to topic
+// and raises the limit of the producer accordingly. If the topic config can
+// not be read, only a warning is printed and records are not validated.
+func resolveMaxMessageBytes(cfg *sarama.Config, topic string) {
+	if requestSizeLimitFlag < 0 {
+		errorExit("Invalid value for --request-size-limit: %v\n", requestSizeLimitFlag)
+	}
+	maxMessageBytes = requestSizeLimitFlag
+
+	if maxMessageBytes == 0 {
+		admin := getClusterAdmin()
+		entries, err := admin.DescribeConfig(sarama.ConfigResource{
+			Type:        sarama.TopicResource,
+			Name:        topic,
+			ConfigNames: []string{"max.message.bytes"},
+		})
+		if err == nil && len(entries) > 0 {
+			maxMessageBytes, err = strconv.Atoi(entries[0].Value)
+		}
+		if err != nil || maxMessageBytes <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: unable to read max.message.bytes of topic %v, record sizes are not validated\n", topic)
+			maxMessageBytes = 0
+			if chunkFlag {
+				errorExit("--chunk requires a known size limit, set --request-size-limit\n")
+			}
+			return
+		}
+	}
+
+	cfg.Producer.MaxMessageBytes = maxMessageBytes
+}
+
+// messageSize estimates the size of msg in a record batch.
+func messageSize(msg *sarama.ProducerMessage) int {
+	size := recordOverhead
+	if msg.Key != nil {
+		size += msg.Key.Length()
+	}
+	if msg.Value != nil {
+		size += msg.Value.Length()
+	}
+	for _, h := range msg.Headers {
+		size += len(h.Key) + len(h.Value) + 10
+	}
+	return size
+}
+
+// checkMessageSize returns the records to send for msg. Records exceeding the
+// size limit are split into chunks with --chunk, and rejected otherwise.
+func checkMessageSize(msg *sarama.ProducerMessage) ([]*sarama.ProducerMessage, error) {
+	size := messageSize(msg)
+	if maxMessageBytes == 0 || size <= maxMessageBytes {
+		return []*sarama.ProducerMessage{msg}, nil
+	}
+	if !chunkFlag {
+		return nil, fmt.Errorf("Record of about %v bytes exceeds the limit of %v bytes of topic %v. Use --chunk to split it", size, maxMessageBytes, msg.Topic)
+	}
+	return chunkMessage(msg)
+}
+
+// chunkMessage splits the value of msg into records fitting the size limit.
+// All chunks keep key and headers of msg. sendMessage sends the remaining
+// chunks to the partition of the first one, so that they are appended to the
+// same partition in order even without key.
+func chunkMessage(msg *sarama.ProducerMessage) ([]*sarama.ProducerMessage, error) {
+	if msg.Value == nil {
+		return nil, fmt.Errorf("Key and headers of the record exceed the limit of %v bytes of topic %v", maxMessageBytes, msg.Topic)
+	}
+	value, err := msg.Value.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	// Reserve room for the chunk headers, whose values are at most 20
+	// digits each.
+	fixed := messageSize(msg) - len(value) + len(chunkIDHeader) + len(chunkIndexHeader) + len(chunkCountHeader) + 3*(20+10)
+	chunkSize := maxMessageBytes - fixed
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("Key and headers of the record exceed the limit of %v bytes of topic %v", maxMessageBytes, msg.Topic)
+	}
+
+	count := (len(value) + chunkSize - 1) / chunkSize
+	chunks := make([]*sarama.ProducerMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		chunk := *msg
+		chunk.Value = sarama.ByteEncoder(value[i*chunkSize : end])
+		chunk.Headers = append(append([]sarama.RecordHeader{}, msg.Headers...),
+			sarama.RecordHeader{Key: []byte(chunkIDHeader), Value: []byte(hex.EncodeToString(id))},
+			sarama.RecordHeader{Key: []byte(chunkIndexHeader), Value: []byte(strconv.Itoa(i))},
+			sarama.RecordHeader{Key: []byte(chunkCountHeader), Value: []byte(strconv.Itoa(count))},
+		)
+		chunks = append(chunks, &chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	queryTimeFlag      string
+	queryPartitionFlag int32
+)
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.AddCommand(queryOffsetCmd)
+
+	queryOffsetCmd.Flags().StringVar(&queryTimeFlag, "time", "", "Timestamp to resolve offsets for, in RFC3339 format (e.g. 2019-07-01T12:00:00Z)")
+	queryOffsetCmd.Flags().Int32Var(&queryPartitionFlag, "partition", -1, "Only resolve the offset of this partition")
+	queryOffsetCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Query information about data in topics",
+}
+
+var queryOffsetCmd = &cobra.Command{
+	Use:   "offset TOPIC",
+	Short: "Resolve the offset of each partition at a given time",
+	Long:  "Resolve the offset of the first message of each partition with a timestamp at or after the given time. An offset of -1 means that no such message exists.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		topic := args[0]
+
+		if queryTimeFlag == "" {
+			errorExit("The --time flag is required\n")
+		}
+		ts, err := parseTimestamp(queryTimeFlag)
+		if err != nil {
+			errorExit("Unable to parse time: %v\n", err)
+		}
+
+		client := getClient()
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			errorExit("Unable to get partitions: %v\n", err)
+		}
+
+		if queryPartitionFlag >= 0 {
+			if !containsPartition(partitions, queryPartitionFlag) {
+				errorExit("Partition %v does not exist in topic %v\n", queryPartitionFlag, topic)
+			}
+			partitions = []int32{queryPartitionFlag}
+		}
+
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		if !noHeaderFlag {
+			fmt.Fprintf(w, "PARTITION\tOFFSET\t\n")
+		}
+
+		for _, partition := range partitions {
+			offset, err := client.GetOffset(topic, partition, timeToMillis(ts))
+			if err != nil {
+				errorExit("Unable to get offset for partition %v: %v\n", partition, err)
+			}
+			fmt.Fprintf(w, "%v\t%v\t\n", partition, offset)
+		}
+		w.Flush()
+	},
+}
+
+// parseTimestamp parses a user supplied RFC3339 timestamp.
+func parseTimestamp(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseDuration parses a duration as time.ParseDuration does, additionally
+// accepting a leading number of days with the unit d, e.g. 2d or 1d12h.
+func parseDuration(s string) (time.Duration, error) {
+	var days time.Duration
+	if i := strings.Index(s, "d"); i >= 0 {
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %v", s)
+		}
+		days = time.Duration(n * float64(24*time.Hour))
+		if s = s[i+1:]; s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return days + d, nil
+}
+
+// timeToMillis converts t to milliseconds since epoch, as used by Kafka.
+func timeToMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func containsPartition(partitions []int32, partition int32) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// describeResourceConfig fetches the config of a resource from broker, sorted
+// by name.
+//
+// With version 0 of the DescribeConfigs API, entries only tell whether they
+// are set to their default. Version 1 adds the source of each entry and,
+// if includeSynonyms is set, the synonyms the effective value was chosen
+// from. As version 1 requires Kafka 1.1, a separate connection which
+// announces that version is opened for it.
+func describeResourceConfig(client sarama.Client, broker *sarama.Broker, resource sarama.ConfigResource, version int16, includeSynonyms bool) ([]*sarama.ConfigEntry, error) {
+	if version > 0 {
+		conf := *client.Config()
+		if !conf.Version.IsAtLeast(sarama.V1_1_0_0) {
+			conf.Version = sarama.V1_1_0_0
+		}
+		broker = sarama.NewBroker(broker.Addr())
+		if err := broker.Open(&conf); err != nil {
+			return nil, err
+		}
+		defer broker.Close()
+	} else if err := broker.Open(client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+		return nil, err
+	}
+
+	resp, err := broker.DescribeConfigs(&sarama.DescribeConfigsRequest{
+		Version:         version,
+		Resources:       []*sarama.ConfigResource{&resource},
+		IncludeSynonyms: includeSynonyms,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range resp.Resources {
+		if r.Name != resource.Name {
+			continue
+		}
+		if r.ErrorMsg != "" {
+			return nil, errors.New(r.ErrorMsg)
+		}
+		sort.Slice(r.Configs, func(i, j int) bool { return r.Configs[i].Name < r.Configs[j].Name })
+		return r.Configs, nil
+	}
+	return nil, fmt.Errorf("no config returned for %v", resource.Name)
+}
+
+// isDefaultConfig returns true if entry is set to its default value.
+func isDefaultConfig(entry *sarama.ConfigEntry) bool {
+	return entry.Default || entry.Source == sarama.SourceDefault
+}
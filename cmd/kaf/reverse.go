@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	reverseFlag       bool
+	reverseWindowFlag int64
+)
+
+func init() {
+	consumeCmd.Flags().BoolVar(&reverseFlag, "reverse", false, "Print the messages of each partition newest first, from the high watermark back to the oldest offset. Partitions are read one after another")
+	consumeCmd.Flags().Int64Var(&reverseWindowFlag, "reverse-window", 500, "Number of offsets read and buffered at once by --reverse")
+}
+
+// consumeReverse emits the messages of each partition from newest to oldest.
+// As Kafka only reads forward, windows ending at a cursor are read, emitted
+// in reverse and the cursor is moved back by the window size.
+func consumeReverse(client sarama.Client, topic string, partitions []int32, emit func(*sarama.ConsumerMessage)) {
+	highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+	for _, partition := range partitions {
+		for cursor := highWatermarks[partition]; ; cursor -= reverseWindowFlag {
+			select {
+			case <-stopConsume:
+				return
+			default:
+			}
+
+			// Retention may have deleted messages since the previous
+			// window.
+			oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+			if err != nil {
+				errorExit("Unable to get oldest offset of partition %v: %v\n", partition, err)
+			}
+			if cursor <= oldest {
+				break
+			}
+			start := cursor - reverseWindowFlag
+			if start < oldest {
+				start = oldest
+			}
+
+			var (
+				mu     sync.Mutex
+				window []*sarama.ConsumerMessage
+			)
+			// The offset before the cursor may not hold a message, e.g.
+			// if it was compacted away. The window then ends with the
+			// first message after the cursor, or at the high watermark.
+			opts := readOptions{
+				start:    map[int32]int64{partition: start},
+				end:      map[int32]int64{partition: cursor},
+				stop:     stopConsume,
+				untilHWM: true,
+			}
+			err = consumePartitions(client, topic, []int32{partition}, opts, func(msg *sarama.ConsumerMessage) {
+				if msg.Offset >= cursor {
+					// Already emitted with the previous window.
+					return
+				}
+				mu.Lock()
+				window = append(window, msg)
+				mu.Unlock()
+			})
+			if err != nil {
+				errorExit("Unable to consume partition %v: %v\n", partition, err)
+			}
+
+			for i := len(window) - 1; i >= 0; i-- {
+				emit(window[i])
+			}
+		}
+	}
+}
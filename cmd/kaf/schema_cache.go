@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	schemaCacheTTLFlag   time.Duration
+	schemaCacheStatsFlag bool
+)
+
+func init() {
+	consumeCmd.Flags().DurationVar(&schemaCacheTTLFlag, "schema-cache-ttl", 0, "Fetch cached schemas from the schema registry again after this long, e.g. for long running consumes of topics with evolving schemas. 0 caches schemas until exit")
+	consumeCmd.Flags().BoolVar(&schemaCacheStatsFlag, "schema-cache-stats", false, "Print the hits and misses of the schema cache on exit")
+}
+
+// configureSchemaCache applies --schema-cache-ttl to the schema cache.
+func configureSchemaCache() {
+	if schemaCacheTTLFlag < 0 {
+		errorExit("--schema-cache-ttl must not be negative\n")
+	}
+	if schemaCache != nil {
+		schemaCache.SetTTL(schemaCacheTTLFlag)
+	}
+}
+
+// printSchemaCacheStats prints the schema cache hits and misses if
+// --schema-cache-stats is set.
+func printSchemaCacheStats() {
+	if !schemaCacheStatsFlag {
+		return
+	}
+	if schemaCache == nil {
+		fmt.Fprintf(os.Stderr, "Schema cache not used, no schema registry configured.\n")
+		return
+	}
+	stats := schemaCache.Stats()
+	fmt.Fprintf(os.Stderr, "Schema cache: %v hits, %v misses.\n", stats.Hits, stats.Misses)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// sizeHistogram collects the value sizes of messages.
+type sizeHistogram struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (h *sizeHistogram) add(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sizes = append(h.sizes, size)
+}
+
+// sizeBucket counts the sizes between min and max, inclusive.
+type sizeBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// sizeStats is the distribution of value sizes printed by --histogram.
+type sizeStats struct {
+	Count   int          `json:"count"`
+	Min     int          `json:"min"`
+	Max     int          `json:"max"`
+	Mean    float64      `json:"mean"`
+	P50     int          `json:"p50"`
+	P95     int          `json:"p95"`
+	P99     int          `json:"p99"`
+	Buckets []sizeBucket `json:"buckets"`
+}
+
+// stats computes the distribution of all collected sizes. Sizes are bucketed
+// by powers of two.
+func (h *sizeHistogram) stats() sizeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var stats sizeStats
+	stats.Count = len(h.sizes)
+	stats.Buckets = []sizeBucket{}
+	if stats.Count == 0 {
+		return stats
+	}
+
+	sort.Ints(h.sizes)
+	stats.Min = h.sizes[0]
+	stats.Max = h.sizes[len(h.sizes)-1]
+	stats.P50 = percentile(h.sizes, 50)
+	stats.P95 = percentile(h.sizes, 95)
+	stats.P99 = percentile(h.sizes, 99)
+
+	var sum int
+	counts := make([]int, bits.Len(uint(stats.Max))+1)
+	for _, size := range h.sizes {
+		sum += size
+		counts[bits.Len(uint(size))]++
+	}
+	stats.Mean = float64(sum) / float64(stats.Count)
+
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		bucket := sizeBucket{Count: count}
+		if i > 0 {
+			bucket.Min = 1 << uint(i-1)
+			bucket.Max = 1<<uint(i) - 1
+		}
+		stats.Buckets = append(stats.Buckets, bucket)
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted, using the nearest rank
+// method.
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// print prints the distribution of value sizes to stdout, as JSON with
+// --output json.
+func (h *sizeHistogram) print() {
+	stats := h.stats()
+
+	if outputFlag == "json" {
+		b, err := json.Marshal(stats)
+		if err != nil {
+			errorExit("Unable to marshal histogram: %v\n", err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(w, "SIZE\tCOUNT\t\n")
+	}
+	for _, bucket := range stats.Buckets {
+		fmt.Fprintf(w, "%v-%v\t%v\t\n", bucket.Min, bucket.Max, bucket.Count)
+	}
+	w.Flush()
+
+	fmt.Printf("\nMessages: %v, min: %v, max: %v, mean: %.1f, p50: %v, p95: %v, p99: %v\n",
+		stats.Count, stats.Min, stats.Max, stats.Mean, stats.P50, stats.P95, stats.P99)
+}
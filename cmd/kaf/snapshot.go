@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// readOptions control which messages consumePartitions reads.
+type readOptions struct {
+	// start holds the offset to start consuming each partition at, which
+	// may be sarama.OffsetOldest or sarama.OffsetNewest if the partition
+	// has no end offset. Partitions without start offset are consumed from
+	// the oldest offset.
+	start map[int32]int64
+	// end holds the offset up to which each partition is consumed,
+	// exclusive. Partitions without end offset are consumed until stop is
+	// closed.
+	end map[int32]int64
+	// fair consumes at most one message of each partition per round
+	// instead of consuming all partitions concurrently.
+	fair bool
+	// stop ends consuming once closed.
+	stop <-chan struct{}
+	// eofIdle, if set, ends consuming a partition once it caught up with
+	// its high watermark, or no message arrived for this long after the
+	// first fetch and the high watermark was reached or did not move.
+	eofIdle time.Duration
+	// untilHWM ends consuming a partition once a message at its high
+	// watermark - 1 arrived.
+	untilHWM bool
+	// caughtUp, if set, is called for each partition which ended because
+	// of eofIdle without a message at its end offset, e.g. because the
+	// last offsets are transaction markers.
+	caughtUp func(partition int32)
+}
+
+func (opts readOptions) reportCaughtUp(partition int32) {
+	if opts.caughtUp != nil {
+		opts.caughtUp(partition)
+	}
+}
+
+// fairPollInterval is the time to wait before the next round of --fair if
+// no partition had a message available.
+const fairPollInterval = 10 * time.Millisecond
+
+// consumePartitions consumes the given partitions of topic and calls emit for
+// each message. It returns once all partitions reached their end offset or
+// stop was closed. With fair unset, emit is called concurrently.
+func consumePartitions(client sarama.Client, topic string, partitions []int32, opts readOptions, emit func(*sarama.ConsumerMessage)) error {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		readers  []*partitionReader
+		wg       sync.WaitGroup
+	)
+	for _, partition := range partitions {
+		start, ok := opts.start[partition]
+		if !ok {
+			start = sarama.OffsetOldest
+		}
+		end, hasEnd := opts.end[partition]
+		if start < 0 && (hasEnd || opts.eofIdle > 0) {
+			// Resolve sarama.OffsetOldest and sarama.OffsetNewest,
+			// otherwise an empty partition would wait for a message
+			// forever.
+			if start, err = client.GetOffset(topic, partition, start); err != nil {
+				return err
+			}
+		}
+		if hasEnd && start >= end {
+			// Nothing to consume before the end offset.
+			continue
+		}
+		if opts.eofIdle > 0 {
+			newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return err
+			}
+			if start >= newest {
+				// Already caught up.
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(partition int32, start int64) {
+			defer wg.Done()
+			pc, err := consumePartitionRetry(client, consumer, topic, partition, start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			readers = append(readers, &partitionReader{pc: pc, partition: partition, next: start, lastHWM: -1})
+		}(partition, start)
+	}
+	wg.Wait()
+
+	defer func() {
+		for _, r := range readers {
+			r.pc.AsyncClose()
+		}
+	}()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if client.Config().Consumer.Return.Errors {
+		for _, r := range readers {
+			go func(pc sarama.PartitionConsumer) {
+				// Leadership changes are handled by the partition
+				// consumer, which keeps consuming from the new leader.
+				for err := range pc.Errors() {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}(r.pc)
+		}
+	}
+
+	reachedEnd := func(r *partitionReader, msg *sarama.ConsumerMessage) bool {
+		if end, ok := opts.end[msg.Partition]; ok && msg.Offset >= end-1 {
+			return true
+		}
+		// The high watermark is updated by the fetch which returned msg.
+		return (opts.eofIdle > 0 || opts.untilHWM) && msg.Offset+1 >= r.pc.HighWaterMarkOffset()
+	}
+
+	if opts.fair {
+		consumeFair(readers, opts, emit, reachedEnd)
+		return nil
+	}
+
+	for _, r := range readers {
+		wg.Add(1)
+		go func(r *partitionReader) {
+			defer wg.Done()
+			var idle <-chan time.Time
+			if opts.eofIdle > 0 {
+				ticker := time.NewTicker(opts.eofIdle / 4)
+				defer ticker.Stop()
+				idle = ticker.C
+			}
+			for {
+				select {
+				case msg, ok := <-r.pc.Messages():
+					if !ok {
+						return
+					}
+					emit(msg)
+					r.received(msg)
+					if reachedEnd(r, msg) {
+						return
+					}
+				case now := <-idle:
+					if r.idle(now, opts.eofIdle) {
+						opts.reportCaughtUp(r.partition)
+						return
+					}
+				case <-opts.stop:
+					return
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+	return nil
+}
+
+// partitionReader tracks the progress of a partition consumer of
+// consumePartitions to end it at EOF.
+type partitionReader struct {
+	pc        sarama.PartitionConsumer
+	partition int32
+	// next is the offset of the next message.
+	next int64
+	// fetched is set once a fetch response arrived.
+	fetched bool
+	// idleSince is the time of the last message or idle check.
+	idleSince time.Time
+	// lastHWM is the high watermark of the last idle check, or -1.
+	lastHWM int64
+}
+
+func (r *partitionReader) received(msg *sarama.ConsumerMessage) {
+	r.next = msg.Offset + 1
+	r.fetched = true
+	r.idleSince = time.Now()
+}
+
+// idle returns true if the partition has been idle for eofIdle and is caught
+// up. Idle time only counts once a fetch response arrived, which updates the
+// high watermark. A partition behind its high watermark without messages,
+// e.g. because of transaction markers or compacted messages, is caught up
+// once the high watermark did not move for another eofIdle.
+func (r *partitionReader) idle(now time.Time, eofIdle time.Duration) bool {
+	hwm := r.pc.HighWaterMarkOffset()
+	if !r.fetched {
+		// Partitions are only consumed if they have messages, so the
+		// high watermark is set by the first fetch response.
+		if hwm > 0 {
+			r.fetched = true
+			r.idleSince = now
+		}
+		return false
+	}
+	if now.Sub(r.idleSince) < eofIdle {
+		return false
+	}
+	if r.next >= hwm || hwm == r.lastHWM {
+		return true
+	}
+	r.lastHWM = hwm
+	r.idleSince = now
+	return false
+}
+
+// startOptions select where consuming each partition starts. Later fields
+// take precedence over earlier ones.
+type startOptions struct {
+	// offset is sarama.OffsetOldest or sarama.OffsetNewest.
+	offset int64
+	// follow starts at the last message of each partition.
+	follow bool
+	// tail starts this many messages before the high watermark.
+	tail int64
+	// since, if set, starts at the first message produced at or after it.
+	since time.Time
+	// group, if set, starts at the offsets committed by this group.
+	group string
+	// exact resolves sarama.OffsetOldest and sarama.OffsetNewest to the
+	// offsets they stand for, which consuming up to end offsets requires.
+	exact bool
+}
+
+// resolveStarts returns the start offset of each of the given partitions of
+// topic and their high watermarks, fetched up front and batched per leader
+// broker.
+func resolveStarts(client sarama.Client, topic string, partitions []int32, so startOptions) (starts, highWatermarks map[int32]int64, err error) {
+	highWatermarks = getHighWatermarksFromClient(client, topic, partitions)
+
+	var oldestOffsets map[int32]int64
+	if so.exact || so.tail > 0 || so.group != "" {
+		oldestOffsets = getOldestOffsetsFromClient(client, topic, partitions)
+	}
+
+	var sinceOffsets map[int32]int64
+	if !so.since.IsZero() {
+		sinceOffsets = getOffsetsFromClient(client, topic, partitions, timeToMillis(so.since))
+	}
+
+	var committedOffsets map[int32]int64
+	if so.group != "" {
+		committedOffsets, err = fetchGroupOffsets(client, so.group, topic, partitions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to fetch offsets of group %v: %v", so.group, err)
+		}
+	}
+
+	starts = make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset := so.offset
+		followOffset := highWatermarks[partition] - 1
+
+		if so.follow && followOffset > 0 && so.tail == 0 {
+			offset = followOffset
+			printNotice("Starting on partition %v with offset %v\n", partition, offset)
+		}
+
+		if so.tail > 0 {
+			offset = highWatermarks[partition] - so.tail
+			if offset < oldestOffsets[partition] {
+				offset = oldestOffsets[partition]
+			}
+		}
+
+		if sinceOffset, ok := sinceOffsets[partition]; ok {
+			// No message was produced since then.
+			if sinceOffset < 0 {
+				sinceOffset = highWatermarks[partition]
+			}
+			offset = sinceOffset
+		}
+
+		if committed, ok := committedOffsets[partition]; ok {
+			// Offsets may have been deleted since the commit.
+			offset = committed
+			if offset < oldestOffsets[partition] {
+				offset = oldestOffsets[partition]
+			}
+			if offset > highWatermarks[partition] {
+				offset = highWatermarks[partition]
+			}
+		}
+
+		if so.exact {
+			switch offset {
+			case sarama.OffsetOldest:
+				offset = oldestOffsets[partition]
+			case sarama.OffsetNewest:
+				offset = highWatermarks[partition]
+			}
+		}
+		starts[partition] = offset
+	}
+	return starts, highWatermarks, nil
+}
+
+// snapshotOptions control which messages readSnapshot reads.
+type snapshotOptions struct {
+	// partitions are the partitions to read, or all partitions if nil.
+	partitions []int32
+	// start selects the first message of each partition.
+	start startOptions
+	// fair reads the partitions round-robin instead of concurrently.
+	fair bool
+	// stop ends reading once closed.
+	stop <-chan struct{}
+}
+
+// readSnapshot reads the messages of topic which exist at the time of the
+// call, from the start offsets selected by opts up to the high watermarks,
+// sorted by partition and offset.
+func readSnapshot(client sarama.Client, topic string, opts snapshotOptions) ([]*sarama.ConsumerMessage, error) {
+	partitions := opts.partitions
+	if partitions == nil {
+		var err error
+		partitions, err = client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	so := opts.start
+	so.exact = true
+	starts, highWatermarks, err := resolveStarts(client, topic, partitions, so)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		messages []*sarama.ConsumerMessage
+	)
+	err = consumePartitions(client, topic, partitions, readOptions{
+		start: starts,
+		end:   highWatermarks,
+		fair:  opts.fair,
+		stop:  opts.stop,
+		// The last offsets may be transaction markers.
+		eofIdle: fetchIdleTimeout(client.Config()),
+	}, func(msg *sarama.ConsumerMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		messages = append(messages, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Partition != messages[j].Partition {
+			return messages[i].Partition < messages[j].Partition
+		}
+		return messages[i].Offset < messages[j].Offset
+	})
+	return messages, nil
+}
+
+// consumePartitionRetries is the number of times starting to consume a
+// partition is retried while its leader is unknown or moving.
+const consumePartitionRetries = 5
+
+// consumePartitionRetry starts consuming partition, refreshing the metadata of
+// topic and retrying if its leader changed since the metadata was fetched.
+func consumePartitionRetry(client sarama.Client, consumer sarama.Consumer, topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	for attempt := 0; ; attempt++ {
+		pc, err := consumer.ConsumePartition(topic, partition, offset)
+		if err == nil || attempt >= consumePartitionRetries {
+			return pc, err
+		}
+		switch err {
+		case sarama.ErrNotLeaderForPartition, sarama.ErrLeaderNotAvailable:
+		default:
+			return nil, err
+		}
+		time.Sleep(client.Config().Consumer.Retry.Backoff)
+		if err := client.RefreshMetadata(topic); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// consumeFair reads at most one message of each partition per round, so
+// that a limited number of messages is spread evenly across partitions.
+func consumeFair(readers []*partitionReader, opts readOptions, emit func(*sarama.ConsumerMessage), reachedEnd func(*partitionReader, *sarama.ConsumerMessage) bool) {
+	active := make([]*partitionReader, len(readers))
+	copy(active, readers)
+	remove := func(i int) {
+		active = append(active[:i], active[i+1:]...)
+	}
+
+	for len(active) > 0 {
+		var progressed bool
+		for i := 0; i < len(active); i++ {
+			select {
+			case <-opts.stop:
+				return
+			case msg, ok := <-active[i].pc.Messages():
+				if !ok {
+					remove(i)
+					i--
+					continue
+				}
+				emit(msg)
+				progressed = true
+				active[i].received(msg)
+				if reachedEnd(active[i], msg) {
+					remove(i)
+					i--
+				}
+			default:
+				if opts.eofIdle > 0 && active[i].idle(time.Now(), opts.eofIdle) {
+					opts.reportCaughtUp(active[i].partition)
+					remove(i)
+					i--
+				}
+			}
+		}
+
+		if !progressed {
+			select {
+			case <-opts.stop:
+				return
+			case <-time.After(fairPollInterval):
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const snapshotTestTopic = "test"
+
+// snapshotTestMessages is the number of messages in each partition of the
+// mock broker. Partition 2 is empty.
+var snapshotTestMessages = map[int32]int64{0: 5, 1: 3, 2: 0, 3: 2}
+
+// snapshotTestMarkers is the number of offsets after the messages of each
+// partition which are not delivered, like transaction markers.
+var snapshotTestMarkers = map[int32]int64{3: 2}
+
+// newSnapshotTestClient returns a client of a mock broker serving
+// snapshotTestMessages. Both must be closed.
+func newSnapshotTestClient(t *testing.T) (sarama.Client, *sarama.MockBroker) {
+	broker := sarama.NewMockBroker(t, 1)
+
+	metadata := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	// Offset requests of getOffsetsFromClient are version 1.
+	offsets := sarama.NewMockOffsetResponse(t).SetVersion(1)
+	fetch := sarama.NewMockFetchResponse(t, 2).SetVersion(3)
+	for partition, n := range snapshotTestMessages {
+		metadata.SetLeader(snapshotTestTopic, partition, broker.BrokerID())
+		offsets.SetOffset(snapshotTestTopic, partition, sarama.OffsetOldest, 0)
+		hwm := n + snapshotTestMarkers[partition]
+		offsets.SetOffset(snapshotTestTopic, partition, sarama.OffsetNewest, hwm)
+		for offset := int64(0); offset < n; offset++ {
+			fetch.SetMessage(snapshotTestTopic, partition, offset, sarama.StringEncoder(fmt.Sprintf("%v-%v", partition, offset)))
+		}
+		fetch.SetHighWaterMark(snapshotTestTopic, partition, hwm)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+		"FetchRequest":    fetch,
+	})
+
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V0_10_1_0
+	cfg.Consumer.MaxWaitTime = 10 * time.Millisecond
+	client, err := sarama.NewClient([]string{broker.Addr()}, cfg)
+	if err != nil {
+		broker.Close()
+		t.Fatal(err)
+	}
+	return client, broker
+}
+
+func TestConsumePartitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions []int32
+		opts       readOptions
+		want       []string
+		caughtUp   []string
+	}{
+		{
+			name:       "end offsets",
+			partitions: []int32{0, 1},
+			opts: readOptions{
+				start: map[int32]int64{1: 1},
+				end:   map[int32]int64{0: 3, 1: 3},
+			},
+			want: []string{"0/0", "0/1", "0/2", "1/1", "1/2"},
+		},
+		{
+			name:       "empty partition",
+			partitions: []int32{1, 2},
+			opts: readOptions{
+				end: map[int32]int64{1: 3, 2: 0},
+			},
+			want: []string{"1/0", "1/1", "1/2"},
+		},
+		{
+			name:       "start at end offset",
+			partitions: []int32{0},
+			opts: readOptions{
+				start: map[int32]int64{0: 5},
+				end:   map[int32]int64{0: 5},
+			},
+			want: nil,
+		},
+		{
+			name:       "eof",
+			partitions: []int32{0, 1},
+			opts:       readOptions{eofIdle: time.Second},
+			want:       []string{"0/0", "0/1", "0/2", "0/3", "0/4", "1/0", "1/1", "1/2"},
+		},
+		{
+			name:       "fair eof with empty partition",
+			partitions: []int32{1, 2},
+			opts:       readOptions{fair: true, eofIdle: time.Second},
+			want:       []string{"1/0", "1/1", "1/2"},
+		},
+		{
+			name:       "end offset after transaction markers",
+			partitions: []int32{1, 3},
+			opts: readOptions{
+				end:     map[int32]int64{1: 3, 3: 4},
+				eofIdle: 100 * time.Millisecond,
+			},
+			want:     []string{"1/0", "1/1", "1/2", "3/0", "3/1"},
+			caughtUp: []string{"3"},
+		},
+		{
+			name:       "fair eof with transaction markers",
+			partitions: []int32{3},
+			opts:       readOptions{fair: true, eofIdle: 100 * time.Millisecond},
+			want:       []string{"3/0", "3/1"},
+			caughtUp:   []string{"3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, broker := newSnapshotTestClient(t)
+			defer broker.Close()
+			defer client.Close()
+			var (
+				mu          sync.Mutex
+				got, caught []string
+			)
+			tt.opts.caughtUp = func(partition int32) {
+				mu.Lock()
+				defer mu.Unlock()
+				caught = append(caught, fmt.Sprint(partition))
+			}
+			err := consumePartitions(client, snapshotTestTopic, tt.partitions, tt.opts, func(msg *sarama.ConsumerMessage) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, fmt.Sprintf("%v/%v", msg.Partition, msg.Offset))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.opts.fair {
+				// Partitions are consumed concurrently.
+				sort.Strings(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(caught, tt.caughtUp) {
+				t.Errorf("got caught up partitions %v, want %v", caught, tt.caughtUp)
+			}
+		})
+	}
+}
+
+// fakePartitionConsumer delivers messages which are available right away, so
+// that the rounds of consumeFair are deterministic.
+type fakePartitionConsumer struct {
+	messages chan *sarama.ConsumerMessage
+	hwm      int64
+}
+
+func newFakePartitionConsumer(partition int32, n int64) *fakePartitionConsumer {
+	pc := &fakePartitionConsumer{messages: make(chan *sarama.ConsumerMessage, n), hwm: n}
+	for offset := int64(0); offset < n; offset++ {
+		pc.messages <- &sarama.ConsumerMessage{Topic: snapshotTestTopic, Partition: partition, Offset: offset}
+	}
+	return pc
+}
+
+func (pc *fakePartitionConsumer) AsyncClose()                              {}
+func (pc *fakePartitionConsumer) Close() error                             { return nil }
+func (pc *fakePartitionConsumer) Messages() <-chan *sarama.ConsumerMessage { return pc.messages }
+func (pc *fakePartitionConsumer) Errors() <-chan *sarama.ConsumerError     { return nil }
+func (pc *fakePartitionConsumer) HighWaterMarkOffset() int64               { return pc.hwm }
+
+func TestConsumeFair(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []int64
+		end      map[int32]int64
+		want     []string
+	}{
+		{
+			name:     "round-robin",
+			messages: []int64{5, 3},
+			end:      map[int32]int64{0: 5, 1: 3},
+			want:     []string{"0/0", "1/0", "0/1", "1/1", "0/2", "1/2", "0/3", "0/4"},
+		},
+		{
+			name:     "end offsets",
+			messages: []int64{5, 3, 4},
+			end:      map[int32]int64{0: 2, 1: 3, 2: 1},
+			want:     []string{"0/0", "1/0", "2/0", "0/1", "1/1", "1/2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readers []*partitionReader
+			for partition, n := range tt.messages {
+				pc := newFakePartitionConsumer(int32(partition), n)
+				readers = append(readers, &partitionReader{pc: pc, partition: int32(partition), lastHWM: -1})
+			}
+			reachedEnd := func(r *partitionReader, msg *sarama.ConsumerMessage) bool {
+				return msg.Offset >= tt.end[msg.Partition]-1
+			}
+			var got []string
+			consumeFair(readers, readOptions{end: tt.end, fair: true}, func(msg *sarama.ConsumerMessage) {
+				got = append(got, fmt.Sprintf("%v/%v", msg.Partition, msg.Offset))
+			}, reachedEnd)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	tests := []struct {
+		name string
+		opts snapshotOptions
+		want []string
+	}{
+		{
+			name: "all partitions",
+			want: []string{"0/0", "0/1", "0/2", "0/3", "0/4", "1/0", "1/1", "1/2", "3/0", "3/1"},
+		},
+		{
+			name: "partitions",
+			opts: snapshotOptions{partitions: []int32{1, 2}},
+			want: []string{"1/0", "1/1", "1/2"},
+		},
+		{
+			name: "tail",
+			opts: snapshotOptions{partitions: []int32{0, 1}, start: startOptions{tail: 2}},
+			want: []string{"0/3", "0/4", "1/1", "1/2"},
+		},
+		{
+			name: "newest",
+			opts: snapshotOptions{start: startOptions{offset: sarama.OffsetNewest}},
+			want: nil,
+		},
+		{
+			name: "fair",
+			opts: snapshotOptions{partitions: []int32{0, 1}, fair: true},
+			want: []string{"0/0", "0/1", "0/2", "0/3", "0/4", "1/0", "1/1", "1/2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, broker := newSnapshotTestClient(t)
+			defer broker.Close()
+			defer client.Close()
+			if tt.opts.start.offset == 0 {
+				tt.opts.start.offset = sarama.OffsetOldest
+			}
+			messages, err := readSnapshot(client, snapshotTestTopic, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, msg := range messages {
+				got = append(got, fmt.Sprintf("%v/%v", msg.Partition, msg.Offset))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
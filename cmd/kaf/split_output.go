@@ -0,0 +1,24 @@
+package main
+
+var splitByPartitionFlag string
+
+func init() {
+	consumeCmd.Flags().StringVar(&splitByPartitionFlag, "output-split-by-partition", "", "Write the messages of each partition to its own file in this directory instead of stdout, e.g. partition-0.jsonl. Requires --output json or raw. Existing files are overwritten")
+}
+
+// splitOutputExt returns the file extension of --output-split-by-partition
+// files, exiting if the output can not be split.
+func splitOutputExt() string {
+	if orderByTime || keyCounts != nil || sizes != nil {
+		errorExit("--output-split-by-partition can not be combined with --order-by-time, --top or --histogram\n")
+	}
+	switch outputFlag {
+	case "json":
+		return "jsonl"
+	case "raw":
+		return "txt"
+	default:
+		errorExit("--output-split-by-partition requires --output json or raw\n")
+		return ""
+	}
+}
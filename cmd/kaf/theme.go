@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	prettyjson "github.com/hokaccha/go-prettyjson"
+)
+
+// applyTheme sets the colors of a JSON formatter. Possible themes are
+// default, solarized and mono.
+func applyTheme(f *prettyjson.Formatter, theme string) error {
+	switch theme {
+	case "default":
+		defaults := prettyjson.NewFormatter()
+		f.KeyColor = defaults.KeyColor
+		f.StringColor = defaults.StringColor
+		f.BoolColor = defaults.BoolColor
+		f.NumberColor = defaults.NumberColor
+		f.NullColor = defaults.NullColor
+		f.DisabledColor = false
+	case "solarized":
+		// Accent colors only, without bold, readable on light and dark
+		// backgrounds alike.
+		f.KeyColor = color.New(color.FgBlue)
+		f.StringColor = color.New(color.FgCyan)
+		f.BoolColor = color.New(color.FgMagenta)
+		f.NumberColor = color.New(color.FgYellow)
+		f.NullColor = color.New(color.FgHiBlack)
+		f.DisabledColor = false
+	case "mono":
+		f.DisabledColor = true
+	default:
+		return fmt.Errorf("unknown theme %v", theme)
+	}
+	return nil
+}
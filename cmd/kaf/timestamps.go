@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeZoneFlag   string
+	timeFormatFlag string
+	timeLocation   *time.Location
+)
+
+func init() {
+	consumeCmd.Flags().StringVar(&timeZoneFlag, "time-zone", "", "Time zone timestamps are printed in, e.g. Local, UTC or Europe/Berlin")
+	consumeCmd.Flags().StringVar(&timeFormatFlag, "time-format", "", "Format of printed timestamps. Possible values: rfc3339, rfc3339nano, unix, unixms, or a Go time layout like '2006-01-02 15:04:05'. Does not apply to --output json")
+}
+
+// parseTimeFlags loads the zone of --time-zone.
+func parseTimeFlags() error {
+	if timeZoneFlag != "" {
+		loc, err := time.LoadLocation(timeZoneFlag)
+		if err != nil {
+			return fmt.Errorf("Invalid value for --time-zone: %v", err)
+		}
+		timeLocation = loc
+	}
+	return nil
+}
+
+// localTime returns t in the zone of --time-zone.
+func localTime(t time.Time) time.Time {
+	if timeLocation == nil {
+		return t
+	}
+	return t.In(timeLocation)
+}
+
+// formatTimestamp formats t according to --time-zone and --time-format, or
+// with layout if no format is given. An empty layout selects the default
+// format of time.Time.
+func formatTimestamp(t time.Time, layout string) string {
+	t = localTime(t)
+	switch strings.ToLower(timeFormatFlag) {
+	case "":
+	case "rfc3339":
+		layout = time.RFC3339
+	case "rfc3339nano":
+		layout = time.RFC3339Nano
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(timeToMillis(t), 10)
+	default:
+		layout = timeFormatFlag
+	}
+	if layout == "" {
+		return t.String()
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// keyCounter counts messages per key. At most maxKeys distinct keys are
+// tracked to bound memory, messages with further keys are only counted as
+// overflow.
+type keyCounter struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	maxKeys  int
+	overflow int
+}
+
+func newKeyCounter(maxKeys int) *keyCounter {
+	return &keyCounter{counts: make(map[string]int), maxKeys: maxKeys}
+}
+
+func (c *keyCounter) add(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counts[key]; !ok && c.maxKeys > 0 && len(c.counts) >= c.maxKeys {
+		c.overflow++
+		return
+	}
+	c.counts[key]++
+}
+
+// printTop prints the n keys with the most messages, most frequent first.
+func (c *keyCounter) printTop(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.counts))
+	for key := range c.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c.counts[keys[i]] != c.counts[keys[j]] {
+			return c.counts[keys[i]] > c.counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(w, "KEY\tCOUNT\t\n")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "%v\t%v\t\n", key, c.counts[key])
+	}
+	w.Flush()
+
+	if c.overflow > 0 {
+		fmt.Fprintf(os.Stderr, "Only the first %v distinct keys were tracked, %v messages with other keys were not counted.\n", c.maxKeys, c.overflow)
+	}
+}
@@ -1,20 +1,34 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/spf13/cobra"
+
+	"github.com/birdayz/kaf"
 )
 
 var (
-	partitionsFlag int32
-	replicasFlag   int16
-	noHeaderFlag   bool
-	compactFlag    bool
+	partitionsFlag      int32
+	replicasFlag        int16
+	noHeaderFlag        bool
+	compactFlag         bool
+	offsetsOnlyFlag     bool
+	includeSynonymsFlag bool
+	watchLagFlag        bool
+	sampleIntervalFlag  time.Duration
+	fromTopicFlag       string
+	sortFlag            string
+	reverseSortFlag     bool
+	topicPartitionFlag  int32
+	validateOnlyFlag    bool
+	ifNotExistsFlag     bool
 )
 
 func init() {
@@ -24,12 +38,26 @@ func init() {
 	topicCmd.AddCommand(deleteTopicCmd)
 	topicCmd.AddCommand(lsTopicsCmd)
 	topicCmd.AddCommand(describeTopicCmd)
+	topicCmd.AddCommand(validateTopicNameCmd)
 
 	createTopicCmd.Flags().Int32VarP(&partitionsFlag, "partitions", "p", int32(1), "Number of partitions")
 	createTopicCmd.Flags().Int16VarP(&replicasFlag, "replicas", "r", int16(1), "Number of replicas")
 	createTopicCmd.Flags().BoolVarP(&compactFlag, "compact", "c", false, "Enable topic compaction")
+	createTopicCmd.Flags().BoolVar(&validateOnlyFlag, "validate-only", false, "Let the brokers validate the topic, e.g. its partitions, replication factor and config, without creating it. Exits with code 1 if validation fails")
+	createTopicCmd.Flags().BoolVar(&ifNotExistsFlag, "if-not-exists", false, "Succeed if the topic already exists, e.g. in provisioning scripts. Partitions and config of an existing topic are not changed, use topic apply for that")
+	createTopicCmd.Flags().StringVar(&fromTopicFlag, "from", "", "Copy partitions, replicas and non-default config of this topic. Explicitly set flags take precedence")
 
 	lsTopicsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	addLayoutFlags(lsTopicsCmd)
+	lsTopicsCmd.Flags().StringVar(&sortFlag, "sort", "name", "Sort topics by this column. Possible values: name, partitions, replicas")
+	lsTopicsCmd.Flags().BoolVar(&reverseSortFlag, "reverse", false, "Reverse the sort order")
+	lsTopicsCmd.Flags().BoolVar(&pagerFlag, "pager", false, "Page output through $KAF_PAGER, $PAGER or less if stdout is a terminal")
+
+	describeTopicCmd.Flags().BoolVar(&offsetsOnlyFlag, "offsets-only", false, "Only print the oldest offset and high watermark of each partition. Skips fetching the topic config")
+	describeTopicCmd.Flags().BoolVar(&watchLagFlag, "watch-lag", false, "Sample the high watermarks of all partitions twice and print how many messages were produced to each partition in between. Partitions without new messages are marked as stalled")
+	describeTopicCmd.Flags().DurationVar(&sampleIntervalFlag, "sample-interval", 2*time.Second, "Time between the two samples of --watch-lag")
+	describeTopicCmd.Flags().BoolVar(&includeSynonymsFlag, "include-synonyms", false, "List the synonyms of each config entry, i.e. the broker and default configs its value was chosen from. Requires Kafka 1.1")
+	describeTopicCmd.Flags().Int32Var(&topicPartitionFlag, "partition", -1, "Only describe this partition: its leader, replicas, ISR, oldest offset and high watermark")
 }
 
 var topicCmd = &cobra.Command{
@@ -49,6 +77,12 @@ var lsTopicsCmd = &cobra.Command{
 	Short:   "List topics",
 	Args:    cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
+		checkLayoutFlags()
+		switch sortFlag {
+		case "name", "partitions", "replicas":
+		default:
+			errorExit("Invalid value for --sort: %v\n", sortFlag)
+		}
 		admin := getClusterAdmin()
 
 		topics, err := admin.ListTopics()
@@ -69,29 +103,115 @@ var lsTopicsCmd = &cobra.Command{
 			i++
 		}
 
+		// Topics with equal values are sorted by name.
+		var less func(i, j int) bool
+		switch sortFlag {
+		case "name":
+			less = func(i, j int) bool { return false }
+		case "partitions":
+			less = func(i, j int) bool { return sortedTopics[i].NumPartitions < sortedTopics[j].NumPartitions }
+		case "replicas":
+			less = func(i, j int) bool { return sortedTopics[i].ReplicationFactor < sortedTopics[j].ReplicationFactor }
+		}
 		sort.Slice(sortedTopics, func(i int, j int) bool {
+			if reverseSortFlag {
+				i, j = j, i
+			}
+			if less(i, j) || less(j, i) {
+				return less(i, j)
+			}
 			return sortedTopics[i].name < sortedTopics[j].name
 		})
 
-		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		out, done := startPager()
+		defer done()
+
+		w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 
 		if !noHeaderFlag {
-			fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\t\n")
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "NAME\t\n")
+			case wideFlag:
+				fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\tMIN ISR\tCLEANUP POLICY\tRETENTION MS\t\n")
+			default:
+				fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\t\n")
+			}
 		}
 
 		for _, topic := range sortedTopics {
-			fmt.Fprintf(w, "%v\t%v\t%v\t\n", topic.name, topic.NumPartitions, topic.ReplicationFactor)
+			switch {
+			case compactLayoutFlag:
+				fmt.Fprintf(w, "%v\t\n", topic.name)
+			case wideFlag:
+				// Only config set on the topic itself is known, values
+				// inherited from the broker are shown as -.
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t\n", topic.name, topic.NumPartitions, topic.ReplicationFactor,
+					topicConfigValue(topic.ConfigEntries, "min.insync.replicas"),
+					topicConfigValue(topic.ConfigEntries, "cleanup.policy"),
+					topicConfigValue(topic.ConfigEntries, "retention.ms"))
+			default:
+				fmt.Fprintf(w, "%v\t%v\t%v\t\n", topic.name, topic.NumPartitions, topic.ReplicationFactor)
+			}
 		}
 		w.Flush()
 	},
 }
 
+// topicConfigValue returns the value of a config entry set on a topic, or -
+// if it is not set.
+func topicConfigValue(entries map[string]*string, name string) string {
+	if value, ok := entries[name]; ok && value != nil {
+		return *value
+	}
+	return "-"
+}
+
 var describeTopicCmd = &cobra.Command{
-	Use:   "describe",
+	Use:   "describe [TOPIC]",
 	Short: "Describe topic",
 	Long:  "Describe a topic. Default values of the configuration are omitted.",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		if summaryFlag {
+			describeTopicSummaries(args)
+			return
+		}
+		if preferredLeaderImbalanceFlag {
+			describePreferredLeaderImbalance(args)
+			return
+		}
+		if minISRViolationsFlag {
+			describeMinISRViolations(args)
+			return
+		}
+		if len(args) != 1 {
+			errorExit("A single topic is required, or --summary, --preferred-leader-imbalance or --min-isr-violations to report on several topics\n")
+		}
+		if checkFlag {
+			checkTopic(args[0])
+			return
+		}
+		if cmd.Flags().Changed("partition") {
+			if offsetsOnlyFlag || watchLagFlag || metricsFlag || exportFlag != "" {
+				errorExit("--partition can not be combined with --offsets-only, --watch-lag, --metrics or --export\n")
+			}
+			describeTopicPartition(args[0], topicPartitionFlag)
+			return
+		}
+		if offsetsOnlyFlag {
+			describeTopicOffsets(args[0])
+			return
+		}
+		if watchLagFlag {
+			describeTopicProduceRate(args[0], sampleIntervalFlag, "--sample-interval")
+			return
+		}
+		if metricsFlag {
+			describeTopicProduceRate(args[0], sampleWindowFlag, "--sample-window")
+			return
+		}
+
 		admin := getClusterAdmin()
 
 		topicDetails, err := admin.DescribeTopics([]string{args[0]})
@@ -104,10 +224,19 @@ var describeTopicCmd = &cobra.Command{
 			return
 		}
 
-		cfg, err := admin.DescribeConfig(sarama.ConfigResource{
-			Type: sarama.TopicResource,
-			Name: args[0],
-		})
+		if exportFlag != "" {
+			cfg, err := admin.DescribeConfig(sarama.ConfigResource{
+				Type: sarama.TopicResource,
+				Name: args[0],
+			})
+			if err != nil {
+				errorExit("Unable to describe config: %v\n", err)
+			}
+			printTopicSpec(newTopicSpec(topicDetails[0], cfg), exportFlag)
+			return
+		}
+
+		cfg, err := describeTopicConfig(args[0])
 		if err != nil {
 			errorExit("Unable to describe config: %v\n", err)
 		}
@@ -150,43 +279,268 @@ var describeTopicCmd = &cobra.Command{
 			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t\n", partition.ID, highWatermarks[partition.ID], partition.Leader, sortedReplicas, sortedISR)
 		}
 		fmt.Fprintf(w, "Config:\n")
-		fmt.Fprintf(w, "\tName\tValue\tReadOnly\tSensitive\t\n")
-		fmt.Fprintf(w, "\t----\t-----\t--------\t---------\t\n")
+		fmt.Fprintf(w, "\tName\tValue\tReadOnly\tSensitive\tSource\t\n")
+		fmt.Fprintf(w, "\t----\t-----\t--------\t---------\t------\t\n")
 
 		for _, entry := range cfg {
-			if entry.Default {
+			if isDefaultConfig(entry) {
 				continue
 			}
-			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t\n", entry.Name, entry.Value, entry.ReadOnly, entry.Sensitive)
+			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t\n", entry.Name, entry.Value, entry.ReadOnly, entry.Sensitive, entry.Source)
+			if includeSynonymsFlag {
+				for _, synonym := range entry.Synonyms {
+					fmt.Fprintf(w, "\t  %v\t%v\t\t\t%v\t\n", synonym.ConfigName, synonym.ConfigValue, synonym.Source)
+				}
+			}
 		}
 
 		w.Flush()
 	},
 }
 
+// describeTopicConfig fetches the config of topic including the source of
+// each entry. Brokers older than Kafka 1.1 do not report sources, in which
+// case they are shown as unknown. Synonyms are only included if
+// --include-synonyms is set.
+func describeTopicConfig(topic string) ([]*sarama.ConfigEntry, error) {
+	client := getClient()
+	controller, err := client.Controller()
+	if err != nil {
+		return nil, err
+	}
+
+	resource := sarama.ConfigResource{Type: sarama.TopicResource, Name: topic}
+	cfg, err := describeResourceConfig(client, controller, resource, 1, includeSynonymsFlag)
+	if err != nil && !includeSynonymsFlag {
+		return describeResourceConfig(client, controller, resource, 0, false)
+	}
+	return cfg, err
+}
+
+// partitionOffsets are the offsets of a partition printed by --offsets-only.
+type partitionOffsets struct {
+	Partition     int32 `json:"partition"`
+	Oldest        int64 `json:"oldest"`
+	HighWatermark int64 `json:"high_watermark"`
+}
+
+// describeTopicOffsets prints the oldest offset and high watermark of each
+// partition of topic, using only offset requests.
+func describeTopicOffsets(topic string) {
+	if topicOutputFlag != "default" && topicOutputFlag != "json" {
+		errorExit("Invalid output format %v\n", topicOutputFlag)
+	}
+	client := getClient()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		errorExit("Unable to get partitions: %v\n", err)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	oldest := getOldestOffsetsFromClient(client, topic, partitions)
+	highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+
+	if topicOutputFlag == "json" {
+		offsets := make([]partitionOffsets, 0, len(partitions))
+		for _, partition := range partitions {
+			offsets = append(offsets, partitionOffsets{partition, oldest[partition], highWatermarks[partition]})
+		}
+		b, err := json.MarshalIndent(offsets, "", "  ")
+		if err != nil {
+			errorExit("Unable to encode offsets: %v\n", err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "PARTITION\tOLDEST\tHIGH WATERMARK\t\n")
+	for _, partition := range partitions {
+		fmt.Fprintf(w, "%v\t%v\t%v\t\n", partition, oldest[partition], highWatermarks[partition])
+	}
+	w.Flush()
+}
+
+// describeTopicPartition prints the replicas and offsets of a single
+// partition of topic.
+func describeTopicPartition(topic string, id int32) {
+	admin := getClusterAdmin()
+	topicDetails, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+	if topicDetails[0].Err == sarama.ErrUnknownTopicOrPartition {
+		errorExit("Topic %v not found.\n", topic)
+	}
+
+	var partition *sarama.PartitionMetadata
+	for _, p := range topicDetails[0].Partitions {
+		if p.ID == id {
+			partition = p
+		}
+	}
+	if partition == nil {
+		errorExit("Partition %v of topic %v does not exist, the topic has %v partitions\n", id, topic, len(topicDetails[0].Partitions))
+	}
+	sort.Slice(partition.Replicas, func(i, j int) bool { return partition.Replicas[i] < partition.Replicas[j] })
+	sort.Slice(partition.Isr, func(i, j int) bool { return partition.Isr[i] < partition.Isr[j] })
+
+	client := getClient()
+	oldest := getOldestOffsetsFromClient(client, topic, []int32{id})
+	highWatermarks := getHighWatermarksFromClient(client, topic, []int32{id})
+
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "Topic:\t%v\t\n", topic)
+	fmt.Fprintf(w, "Partition:\t%v\t\n", id)
+	fmt.Fprintf(w, "Leader:\t%v\t\n", partition.Leader)
+	fmt.Fprintf(w, "Replicas:\t%v\t\n", partition.Replicas)
+	fmt.Fprintf(w, "ISR:\t%v\t\n", partition.Isr)
+	if len(partition.OfflineReplicas) > 0 {
+		fmt.Fprintf(w, "Offline Replicas:\t%v\t\n", partition.OfflineReplicas)
+	}
+	fmt.Fprintf(w, "Oldest Offset:\t%v\t\n", oldest[id])
+	fmt.Fprintf(w, "High Watermark:\t%v\t\n", highWatermarks[id])
+	w.Flush()
+}
+
+// describeTopicProduceRate samples the high watermarks of all partitions of
+// topic every interval, given with flag, and prints the number of messages
+// produced in between. Without --watch, it stops after the first interval. As
+// the high watermark also advances for transaction markers, the rate is an
+// approximation.
+func describeTopicProduceRate(topic string, interval time.Duration, flag string) {
+	if interval <= 0 {
+		errorExit("Invalid value for %v: %v\n", flag, interval)
+	}
+	if topicOutputFlag != "default" && topicOutputFlag != "json" {
+		errorExit("Invalid output format %v\n", topicOutputFlag)
+	}
+	client := getClient()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		errorExit("Unable to get partitions: %v\n", err)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	first := getHighWatermarksFromClient(client, topic, partitions)
+	start := time.Now()
+	for {
+		time.Sleep(interval)
+		second := getHighWatermarksFromClient(client, topic, partitions)
+		end := time.Now()
+		elapsed := end.Sub(start).Seconds()
+
+		rate := topicRate{Topic: topic, Time: end, Interval: elapsed}
+		for _, partition := range partitions {
+			delta := second[partition] - first[partition]
+			rate.Partitions = append(rate.Partitions, partitionRate{
+				Partition:     partition,
+				HighWatermark: second[partition],
+				Messages:      delta,
+				Rate:          float64(delta) / elapsed,
+				Stalled:       delta == 0,
+			})
+			rate.Messages += delta
+		}
+		rate.Rate = float64(rate.Messages) / elapsed
+		printTopicRate(&rate)
+
+		if !watchFlag {
+			return
+		}
+		first, start = second, end
+	}
+}
+
 var createTopicCmd = &cobra.Command{
 	Use:   "create TOPIC",
 	Short: "Create a topic",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := kaf.ValidateTopicName(args[0], currentCluster.TopicNamingPolicy); err != nil {
+			errorExit("Could not create topic %v: %v\n", args[0], err)
+		}
+
 		admin := getClusterAdmin()
 
 		compact := "delete"
 		if compactFlag {
 			compact = "compact"
 		}
-		err := admin.CreateTopic(args[0], &sarama.TopicDetail{
+		detail := &sarama.TopicDetail{
 			NumPartitions:     partitionsFlag,
 			ReplicationFactor: replicasFlag,
 			ConfigEntries: map[string]*string{
 				"cleanup.policy": &compact,
 			},
-		}, false)
+		}
+
+		if fromTopicFlag != "" {
+			// Settings of the source topic apply unless set explicitly.
+			spec, err := describeTopicSpec(admin, fromTopicFlag)
+			if err != nil {
+				errorExit("Unable to describe topic %v: %v\n", fromTopicFlag, err)
+			}
+			if spec == nil {
+				errorExit("Topic %v not found.\n", fromTopicFlag)
+			}
+			if !cmd.Flags().Changed("partitions") {
+				detail.NumPartitions = spec.Partitions
+			}
+			if !cmd.Flags().Changed("replicas") {
+				detail.ReplicationFactor = spec.ReplicationFactor
+			}
+			detail.ConfigEntries = configPointers(spec.Config)
+			if cmd.Flags().Changed("compact") {
+				detail.ConfigEntries["cleanup.policy"] = &compact
+			}
+		}
+
+		if validateOnlyFlag {
+			err := admin.CreateTopic(args[0], detail, true)
+			if ifNotExistsFlag && isTopicExistsError(err) {
+				printInfo("Topic %v already exists.\n", args[0])
+				return
+			}
+			if err != nil {
+				errorExit("Validation of topic %v failed: %v\n", args[0], err)
+			}
+			fmt.Printf("Topic %v with %v partitions and replication factor %v would be created.\n", args[0], detail.NumPartitions, detail.ReplicationFactor)
+			return
+		}
+
+		err := admin.CreateTopic(args[0], detail, false)
+		if ifNotExistsFlag && isTopicExistsError(err) {
+			printInfo("Topic %v already exists.\n", args[0])
+			return
+		}
 		if err != nil {
-			fmt.Printf("Could not create topic %v: %v\n", args[0], err.Error())
-		} else {
-			fmt.Printf("Created topic %v.\n", args[0])
+			errorExit("Could not create topic %v: %v\n", args[0], err.Error())
+		}
+		printInfo("Created topic %v.\n", args[0])
+	},
+}
+
+// isTopicExistsError returns true if err reports that a topic to be created
+// already exists.
+func isTopicExistsError(err error) bool {
+	if topicErr, ok := err.(*sarama.TopicError); ok {
+		return topicErr.Err == sarama.ErrTopicAlreadyExists
+	}
+	return err == sarama.ErrTopicAlreadyExists
+}
+
+var validateTopicNameCmd = &cobra.Command{
+	Use:   "validate-name NAME",
+	Short: "Check a topic name against Kafka's rules and the naming policy of the cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := kaf.ValidateTopicName(args[0], currentCluster.TopicNamingPolicy); err != nil {
+			errorExit("%v\n", err)
 		}
+		fmt.Printf("Topic name %v is valid.\n", args[0])
 	},
 }
 
@@ -199,9 +553,8 @@ var deleteTopicCmd = &cobra.Command{
 
 		err := admin.DeleteTopic(args[0])
 		if err != nil {
-			fmt.Printf("Could not delete topic %v: %v\n", args[0], err.Error())
-		} else {
-			fmt.Printf("Deleted topic %v.\n", args[0])
+			errorExit("Could not delete topic %v: %v\n", args[0], err.Error())
 		}
+		printInfo("Deleted topic %v.\n", args[0])
 	},
 }
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/birdayz/kaf"
+)
+
+var (
+	exportFlag    string
+	applyFileFlag string
+	pruneFlag     bool
+	dryRunFlag    bool
+)
+
+func init() {
+	topicCmd.AddCommand(applyTopicCmd)
+
+	describeTopicCmd.Flags().StringVar(&exportFlag, "export", "", "Print a topic spec suitable for topic apply instead of the description. Possible values: yaml, json")
+	applyTopicCmd.Flags().StringVarP(&applyFileFlag, "file", "f", "", "Topic spec file, in YAML or JSON format. May hold a single spec or a list of specs")
+	applyTopicCmd.Flags().BoolVar(&yesFlag, "yes", false, "Apply the planned changes")
+	applyTopicCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Only print the planned changes")
+	applyTopicCmd.Flags().BoolVar(&pruneFlag, "prune", false, "Delete topics missing from the file, which requires --yes. Internal topics and topics starting with _ are never deleted")
+}
+
+// topicSpec is a declarative description of a topic.
+type topicSpec struct {
+	Name              string            `yaml:"name" json:"name"`
+	Partitions        int32             `yaml:"partitions" json:"partitions"`
+	ReplicationFactor int16             `yaml:"replication-factor" json:"replication-factor"`
+	Config            map[string]string `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// newTopicSpec builds the spec of a topic from its metadata and config.
+// Default config values are omitted.
+func newTopicSpec(detail *sarama.TopicMetadata, cfg []sarama.ConfigEntry) *topicSpec {
+	spec := &topicSpec{
+		Name:       detail.Name,
+		Partitions: int32(len(detail.Partitions)),
+		Config:     nonDefaultConfig(cfg),
+	}
+	if len(detail.Partitions) > 0 {
+		spec.ReplicationFactor = int16(len(detail.Partitions[0].Replicas))
+	}
+	return spec
+}
+
+// nonDefaultConfig returns all config entries which are not set to their
+// default value.
+func nonDefaultConfig(cfg []sarama.ConfigEntry) map[string]string {
+	entries := make(map[string]string)
+	for _, entry := range cfg {
+		if entry.Default {
+			continue
+		}
+		entries[entry.Name] = entry.Value
+	}
+	return entries
+}
+
+// describeTopicSpec fetches the spec of a live topic. It returns nil if the
+// topic does not exist.
+func describeTopicSpec(admin sarama.ClusterAdmin, topic string) (*topicSpec, error) {
+	topicDetails, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		return nil, err
+	}
+	if len(topicDetails) == 0 || topicDetails[0].Err == sarama.ErrUnknownTopicOrPartition {
+		return nil, nil
+	}
+
+	cfg, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type: sarama.TopicResource,
+		Name: topic,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newTopicSpec(topicDetails[0], cfg), nil
+}
+
+func printTopicSpec(spec *topicSpec, format string) {
+	switch format {
+	case "yaml":
+		out, err := yaml.Marshal(spec)
+		if err != nil {
+			errorExit("Unable to encode topic spec: %v\n", err)
+		}
+		os.Stdout.Write(out)
+	case "json":
+		out, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			errorExit("Unable to encode topic spec: %v\n", err)
+		}
+		fmt.Println(string(out))
+	default:
+		errorExit("Invalid export format %v\n", format)
+	}
+}
+
+// readTopicSpecs reads a file holding either a single topic spec or a list
+// of topic specs.
+func readTopicSpecs(path string) ([]*topicSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so both formats are handled by the YAML decoder.
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var specs []*topicSpec
+	if _, ok := doc.([]interface{}); ok {
+		if err := yaml.UnmarshalStrict(data, &specs); err != nil {
+			return nil, err
+		}
+	} else {
+		var spec topicSpec
+		if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+			return nil, err
+		}
+		specs = append(specs, &spec)
+	}
+
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if err := validateTopicSpec(spec); err != nil {
+			return nil, err
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("topic %v is specified more than once", spec.Name)
+		}
+		names[spec.Name] = true
+	}
+	return specs, nil
+}
+
+func validateTopicSpec(spec *topicSpec) error {
+	if spec == nil || spec.Name == "" {
+		return fmt.Errorf("topic spec has no name")
+	}
+	if spec.Partitions < 1 {
+		return fmt.Errorf("topic %v must have at least one partition", spec.Name)
+	}
+	if spec.ReplicationFactor < 1 {
+		return fmt.Errorf("topic %v must have a replication factor of at least one", spec.Name)
+	}
+	return nil
+}
+
+var applyTopicCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create or update topics to match specs",
+	Long:  "Create or update topics to match a spec or a list of specs, as printed by topic describe --export. Missing topics are created, partitions are added and the topic config is replaced by the config of the spec. The planned changes are printed first and only applied with --yes. Partitions are never removed, and topics missing from the file are only deleted with --prune.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if applyFileFlag == "" {
+			errorExit("The --file flag is required\n")
+		}
+		specs, err := readTopicSpecs(applyFileFlag)
+		if err != nil {
+			errorExit("Unable to read topic spec: %v\n", err)
+		}
+
+		admin := getClusterAdmin()
+		changes, err := planTopicSpecs(admin, specs, pruneFlag)
+		if err != nil {
+			errorExit("Unable to plan changes: %v\n", err)
+		}
+		if len(changes) == 0 {
+			printInfo("All topics are up to date.\n")
+			return
+		}
+		for _, change := range changes {
+			fmt.Println(change.description)
+		}
+		if dryRunFlag {
+			return
+		}
+		if !yesFlag {
+			var deletes int
+			for _, change := range changes {
+				if change.deletes {
+					deletes++
+				}
+			}
+			if deletes > 0 {
+				errorExit("Confirm applying the changes above, which delete %v topics, with --yes\n", deletes)
+			}
+			errorExit("Confirm applying the changes above with --yes\n")
+		}
+
+		for _, change := range changes {
+			if err := change.apply(); err != nil {
+				errorExit("Unable to apply change of topic %v: %v\n", change.topic, err)
+			}
+		}
+		printInfo("Applied %v changes.\n", len(changes))
+	},
+}
+
+// topicChange is a single planned change of topic apply.
+type topicChange struct {
+	topic       string
+	description string
+	apply       func() error
+	// deletes is set if the change deletes the topic.
+	deletes bool
+}
+
+// planTopicSpecs returns the changes required to make the cluster match
+// specs. With prune, topics without spec are deleted, except for internal
+// topics and topics starting with _, which are used by Kafka and its tools.
+func planTopicSpecs(admin sarama.ClusterAdmin, specs []*topicSpec, prune bool) ([]topicChange, error) {
+	var changes []topicChange
+	for _, spec := range specs {
+		specChanges, err := planTopicSpec(admin, spec)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, specChanges...)
+	}
+	if !prune {
+		return changes, nil
+	}
+
+	topics, err := admin.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+	specified := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		specified[spec.Name] = true
+	}
+	var names []string
+	for name := range topics {
+		if !specified[name] && !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return changes, nil
+	}
+	metadata, err := admin.DescribeTopics(names)
+	if err != nil {
+		return nil, err
+	}
+	names = names[:0]
+	for _, meta := range metadata {
+		if meta.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("unable to describe topic %v: %v", meta.Name, meta.Err)
+		}
+		if !meta.IsInternal {
+			names = append(names, meta.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		name := name
+		changes = append(changes, topicChange{
+			topic:       name,
+			description: fmt.Sprintf("- delete topic %v", name),
+			apply:       func() error { return admin.DeleteTopic(name) },
+			deletes:     true,
+		})
+	}
+	return changes, nil
+}
+
+// planTopicSpec returns the changes required to create or alter the topic
+// described by spec.
+func planTopicSpec(admin sarama.ClusterAdmin, spec *topicSpec) ([]topicChange, error) {
+	live, err := describeTopicSpec(admin, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if live == nil {
+		if err := kaf.ValidateTopicName(spec.Name, currentCluster.TopicNamingPolicy); err != nil {
+			return nil, err
+		}
+		return []topicChange{{
+			topic:       spec.Name,
+			description: fmt.Sprintf("+ create topic %v with %v partitions and replication factor %v", spec.Name, spec.Partitions, spec.ReplicationFactor),
+			apply: func() error {
+				return admin.CreateTopic(spec.Name, &sarama.TopicDetail{
+					NumPartitions:     spec.Partitions,
+					ReplicationFactor: spec.ReplicationFactor,
+					ConfigEntries:     configPointers(spec.Config),
+				}, false)
+			},
+		}}, nil
+	}
+
+	if live.ReplicationFactor != spec.ReplicationFactor {
+		fmt.Fprintf(os.Stderr, "Replication factor of topic %v is %v, changing it to %v is not supported.\n", spec.Name, live.ReplicationFactor, spec.ReplicationFactor)
+	}
+
+	var changes []topicChange
+	switch {
+	case spec.Partitions < live.Partitions:
+		return nil, fmt.Errorf("topic %v has %v partitions, partitions can not be removed", spec.Name, live.Partitions)
+	case spec.Partitions > live.Partitions:
+		if err := requireFeature(getConfig(), featureCreatePartitions); err != nil {
+			return nil, err
+		}
+		changes = append(changes, topicChange{
+			topic:       spec.Name,
+			description: fmt.Sprintf("~ increase partitions of topic %v from %v to %v", spec.Name, live.Partitions, spec.Partitions),
+			apply: func() error {
+				return admin.CreatePartitions(spec.Name, spec.Partitions, nil, false)
+			},
+		})
+	}
+
+	if configChanges := diffConfig(live.Config, spec.Config); len(configChanges) > 0 {
+		changes = append(changes, topicChange{
+			topic:       spec.Name,
+			description: fmt.Sprintf("~ change config of topic %v: %v", spec.Name, strings.Join(configChanges, ", ")),
+			apply: func() error {
+				return admin.AlterConfig(sarama.TopicResource, spec.Name, configPointers(spec.Config), false)
+			},
+		})
+	}
+	return changes, nil
+}
+
+// diffConfig returns a human readable list of changes required to get from
+// the current to the desired config.
+func diffConfig(current, desired map[string]string) (changes []string) {
+	for name, value := range desired {
+		old, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("set %v=%v", name, value))
+		case old != value:
+			changes = append(changes, fmt.Sprintf("set %v=%v (was %v)", name, value, old))
+		}
+	}
+	for name, value := range current {
+		if _, ok := desired[name]; !ok {
+			changes = append(changes, fmt.Sprintf("reset %v (was %v)", name, value))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func configPointers(cfg map[string]string) map[string]*string {
+	entries := make(map[string]*string, len(cfg))
+	for name, value := range cfg {
+		value := value
+		entries[name] = &value
+	}
+	return entries
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	checkFlag          bool
+	requireFullISRFlag bool
+	requireLeadersFlag bool
+	requireMinISRFlag  bool
+)
+
+func init() {
+	describeTopicCmd.Flags().BoolVar(&checkFlag, "check", false, "Check the health of the topic instead of describing it. Prints the problems found and exits with 1 if any check fails. Prints nothing on success unless --verbose is set. Without any --require-* flag, all checks are run")
+	describeTopicCmd.Flags().BoolVar(&requireLeadersFlag, "require-leaders", false, "With --check, fail if a partition has no leader")
+	describeTopicCmd.Flags().BoolVar(&requireFullISRFlag, "require-full-isr", false, "With --check, fail if a partition has replicas out of sync")
+	describeTopicCmd.Flags().BoolVar(&requireMinISRFlag, "require-min-isr", false, "With --check, fail if a partition has fewer in-sync replicas than min.insync.replicas")
+}
+
+// checkTopic runs the health checks selected via --require-* flags and exits
+// with 1 if any of them fails.
+func checkTopic(topic string) {
+	if !requireLeadersFlag && !requireFullISRFlag && !requireMinISRFlag {
+		requireLeadersFlag, requireFullISRFlag, requireMinISRFlag = true, true, true
+	}
+
+	admin := getClusterAdmin()
+	topicDetails, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+	if len(topicDetails) == 0 || topicDetails[0].Err == sarama.ErrUnknownTopicOrPartition {
+		errorExit("Topic %v not found.\n", topic)
+	}
+	partitions := topicDetails[0].Partitions
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+
+	minISR := 0
+	if requireMinISRFlag {
+		minISR, err = topicMinISR(admin, topic)
+		if err != nil {
+			errorExit("Unable to describe config of topic %v: %v\n", topic, err)
+		}
+	}
+
+	var problems []string
+	for _, partition := range partitions {
+		if requireLeadersFlag && partition.Leader < 0 {
+			problems = append(problems, fmt.Sprintf("Partition %v has no leader", partition.ID))
+		}
+		if requireFullISRFlag && len(partition.Isr) < len(partition.Replicas) {
+			problems = append(problems, fmt.Sprintf("Partition %v has %v of %v replicas in sync", partition.ID, len(partition.Isr), len(partition.Replicas)))
+		}
+		if requireMinISRFlag && len(partition.Isr) < minISR {
+			problems = append(problems, fmt.Sprintf("Partition %v has %v in-sync replicas, min.insync.replicas is %v", partition.ID, len(partition.Isr), minISR))
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		errorExit("Topic %v failed %v checks\n", topic, len(problems))
+	}
+	if verbose {
+		fmt.Printf("Topic %v is healthy.\n", topic)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clusterAFlag   string
+	clusterBFlag   string
+	diffOutputFlag string
+)
+
+func init() {
+	topicCmd.AddCommand(diffTopicCmd)
+
+	diffTopicCmd.Flags().StringVar(&clusterAFlag, "cluster-a", "", "Name of the configured cluster of TOPIC_A (default current cluster)")
+	diffTopicCmd.Flags().StringVar(&clusterBFlag, "cluster-b", "", "Name of the configured cluster of TOPIC_B (default current cluster)")
+	diffTopicCmd.Flags().StringVarP(&diffOutputFlag, "output", "o", "default", "Output format. Possible values: default, json")
+}
+
+// topicDifference is a setting which differs between two topics. Missing
+// config entries are represented as "-".
+type topicDifference struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+var diffTopicCmd = &cobra.Command{
+	Use:   "diff TOPIC_A TOPIC_B",
+	Short: "Compare the layout and config of two topics",
+	Long:  "Compare partitions, replication factor and non-default config of two topics, which may live in different clusters.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if diffOutputFlag != "default" && diffOutputFlag != "json" {
+			errorExit("Invalid output format %v\n", diffOutputFlag)
+		}
+
+		a := describeTopicSpecIn(clusterAFlag, args[0])
+		b := describeTopicSpecIn(clusterBFlag, args[1])
+		diffs := diffTopicSpecs(a, b)
+
+		if diffOutputFlag == "json" {
+			out, err := json.MarshalIndent(struct {
+				A           *topicSpec        `json:"a"`
+				B           *topicSpec        `json:"b"`
+				Differences []topicDifference `json:"differences"`
+			}{a, b, diffs}, "", "  ")
+			if err != nil {
+				errorExit("Unable to encode differences: %v\n", err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		if len(diffs) == 0 {
+			fmt.Println("Topics are identical.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "FIELD\t%v\t%v\t\n", args[0], args[1])
+		for _, diff := range diffs {
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", diff.Field, diff.A, diff.B)
+		}
+		w.Flush()
+	},
+}
+
+// describeTopicSpecIn describes topic in the configured cluster of the given
+// name, or in the current cluster if name is empty.
+func describeTopicSpecIn(clusterName, topic string) *topicSpec {
+	var admin sarama.ClusterAdmin
+	if clusterName == "" {
+		admin = getClusterAdmin()
+	} else {
+		cluster := config.Cluster(clusterName)
+		if cluster == nil {
+			errorExit("Could not find cluster with name %v\n", clusterName)
+		}
+		admin = getClusterAdminOf(cluster)
+	}
+
+	spec, err := describeTopicSpec(admin, topic)
+	if err != nil {
+		errorExit("Unable to describe topic %v: %v\n", topic, err)
+	}
+	if spec == nil {
+		errorExit("Topic %v does not exist\n", topic)
+	}
+	return spec
+}
+
+// diffTopicSpecs returns the settings which differ between a and b. Config
+// entries are sorted by name.
+func diffTopicSpecs(a, b *topicSpec) []topicDifference {
+	diffs := []topicDifference{}
+	if a.Partitions != b.Partitions {
+		diffs = append(diffs, topicDifference{"partitions", fmt.Sprint(a.Partitions), fmt.Sprint(b.Partitions)})
+	}
+	if a.ReplicationFactor != b.ReplicationFactor {
+		diffs = append(diffs, topicDifference{"replication-factor", fmt.Sprint(a.ReplicationFactor), fmt.Sprint(b.ReplicationFactor)})
+	}
+
+	names := make(map[string]struct{})
+	for name := range a.Config {
+		names[name] = struct{}{}
+	}
+	for name := range b.Config {
+		names[name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		valueA, okA := a.Config[name]
+		valueB, okB := b.Config[name]
+		if okA && okB && valueA == valueB {
+			continue
+		}
+		if !okA {
+			valueA = "-"
+		}
+		if !okB {
+			valueB = "-"
+		}
+		diffs = append(diffs, topicDifference{name, valueA, valueB})
+	}
+	return diffs
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var preferredLeaderImbalanceFlag bool
+
+func init() {
+	describeTopicCmd.Flags().BoolVar(&preferredLeaderImbalanceFlag, "preferred-leader-imbalance", false, "Print per broker how many partitions it leads and for how many it is the preferred leader, i.e. the first replica, followed by all partitions not led by their preferred leader. Without a topic, all topics are included")
+}
+
+// brokerLeadership counts the partitions a broker leads and is the preferred
+// leader of.
+type brokerLeadership struct {
+	Broker    int32 `json:"broker"`
+	Leads     int   `json:"leads"`
+	Preferred int   `json:"preferred"`
+	Imbalance int   `json:"imbalance"`
+}
+
+// misplacedLeader is a partition not led by its preferred leader. Leader is
+// -1 if the partition has no leader.
+type misplacedLeader struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Leader    int32  `json:"leader"`
+	Preferred int32  `json:"preferred"`
+}
+
+// describePreferredLeaderImbalance reports how far partition leadership of
+// the given topics, or of all topics if none are given, deviates from the
+// preferred leaders.
+func describePreferredLeaderImbalance(names []string) {
+	if topicOutputFlag != "default" && topicOutputFlag != "json" {
+		errorExit("Invalid output format %v\n", topicOutputFlag)
+	}
+
+	admin := getClusterAdmin()
+	if len(names) == 0 {
+		topics, err := admin.ListTopics()
+		if err != nil {
+			errorExit("Unable to list topics: %v\n", err)
+		}
+		for name := range topics {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	metadata, err := admin.DescribeTopics(names)
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+
+	brokers := make(map[int32]*brokerLeadership)
+	broker := func(id int32) *brokerLeadership {
+		if brokers[id] == nil {
+			brokers[id] = &brokerLeadership{Broker: id}
+		}
+		return brokers[id]
+	}
+	misplaced := []misplacedLeader{}
+	for _, meta := range metadata {
+		if meta.Err != sarama.ErrNoError {
+			errorExit("Unable to describe topic %v: %v\n", meta.Name, meta.Err)
+		}
+		for _, partition := range meta.Partitions {
+			if len(partition.Replicas) == 0 {
+				continue
+			}
+			preferred := partition.Replicas[0]
+			broker(preferred).Preferred++
+			if partition.Leader >= 0 {
+				broker(partition.Leader).Leads++
+			}
+			if partition.Leader != preferred {
+				misplaced = append(misplaced, misplacedLeader{meta.Name, partition.ID, partition.Leader, preferred})
+			}
+		}
+	}
+
+	leaderships := make([]*brokerLeadership, 0, len(brokers))
+	for _, b := range brokers {
+		b.Imbalance = b.Leads - b.Preferred
+		leaderships = append(leaderships, b)
+	}
+	sort.Slice(leaderships, func(i, j int) bool { return leaderships[i].Broker < leaderships[j].Broker })
+	sort.Slice(misplaced, func(i, j int) bool {
+		if misplaced[i].Topic != misplaced[j].Topic {
+			return misplaced[i].Topic < misplaced[j].Topic
+		}
+		return misplaced[i].Partition < misplaced[j].Partition
+	})
+
+	out, done := startPager()
+	defer done()
+
+	if topicOutputFlag == "json" {
+		b, err := json.MarshalIndent(struct {
+			Brokers   []*brokerLeadership `json:"brokers"`
+			Misplaced []misplacedLeader   `json:"misplaced"`
+		}{leaderships, misplaced}, "", "  ")
+		if err != nil {
+			errorExit("Unable to encode report: %v\n", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return
+	}
+
+	w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "BROKER\tLEADS\tPREFERRED\tIMBALANCE\t\n")
+	for _, b := range leaderships {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%+d\t\n", b.Broker, b.Leads, b.Preferred, b.Imbalance)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if len(misplaced) == 0 {
+		fmt.Fprintln(out, "All partitions are led by their preferred leader.")
+		return
+	}
+	fmt.Fprintf(out, "%v partitions are not led by their preferred leader:\n", len(misplaced))
+	w = tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "TOPIC\tPARTITION\tLEADER\tPREFERRED\t\n")
+	for _, m := range misplaced {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", m.Topic, m.Partition, m.Leader, m.Preferred)
+	}
+	w.Flush()
+}
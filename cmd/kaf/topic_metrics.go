@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	metricsFlag      bool
+	sampleWindowFlag time.Duration
+	topicOutputFlag  string
+)
+
+func init() {
+	describeTopicCmd.Flags().BoolVar(&metricsFlag, "metrics", false, "Estimate the produce rate of each partition and of the topic by sampling the high watermarks at the start and end of --sample-window")
+	describeTopicCmd.Flags().DurationVar(&sampleWindowFlag, "sample-window", 5*time.Second, "Sampling window of --metrics")
+	describeTopicCmd.Flags().BoolVar(&watchFlag, "watch", false, "Print --metrics or --watch-lag for consecutive sampling intervals until interrupted")
+	describeTopicCmd.Flags().StringVarP(&topicOutputFlag, "output", "o", "default", "Output format of --metrics, --watch-lag, --offsets-only and --summary. Possible values: default, json. json prints one JSON object per sampling interval or topic.")
+}
+
+// partitionRate is the estimated produce rate of a partition.
+type partitionRate struct {
+	Partition     int32   `json:"partition"`
+	HighWatermark int64   `json:"high_watermark"`
+	Messages      int64   `json:"messages"`
+	Rate          float64 `json:"messages_per_second"`
+	Stalled       bool    `json:"stalled"`
+}
+
+// topicRate is the estimated produce rate of a topic during one sampling
+// interval.
+type topicRate struct {
+	Topic      string          `json:"topic"`
+	Time       time.Time       `json:"time"`
+	Interval   float64         `json:"interval_seconds"`
+	Partitions []partitionRate `json:"partitions"`
+	Messages   int64           `json:"messages"`
+	Rate       float64         `json:"messages_per_second"`
+}
+
+// printTopicRate prints the produce rate of one sampling interval.
+func printTopicRate(rate *topicRate) {
+	if topicOutputFlag == "json" {
+		b, err := json.Marshal(rate)
+		if err != nil {
+			errorExit("Unable to marshal metrics: %v\n", err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "PARTITION\tHIGH WATERMARK\tNEW MESSAGES\tMESSAGES/S\t\t\n")
+	for _, p := range rate.Partitions {
+		var stalled string
+		if p.Stalled {
+			stalled = "stalled"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%.1f\t%v\t\n", p.Partition, p.HighWatermark, p.Messages, p.Rate, stalled)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%v\t%.1f\t\t\n", rate.Messages, rate.Rate)
+	w.Flush()
+	if watchFlag {
+		fmt.Println()
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var minISRViolationsFlag bool
+
+func init() {
+	describeTopicCmd.Flags().BoolVar(&minISRViolationsFlag, "min-isr-violations", false, "List partitions with fewer in-sync replicas than min.insync.replicas, which reject writes with acks=all. Without a topic, all topics are scanned. With --check, exits with 1 if any partition violates it")
+}
+
+// minISRViolation is a partition with fewer in-sync replicas than
+// min.insync.replicas of its topic.
+type minISRViolation struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	ISR       int    `json:"isr"`
+	MinISR    int    `json:"min_isr"`
+	Replicas  int    `json:"replicas"`
+}
+
+// topicMinISR returns min.insync.replicas of topic, which is the broker
+// default if the topic does not override it.
+func topicMinISR(admin sarama.ClusterAdmin, topic string) (int, error) {
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"min.insync.replicas"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("min.insync.replicas of topic %v is unknown", topic)
+	}
+	minISR, err := strconv.Atoi(entries[0].Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid min.insync.replicas %v: %v", entries[0].Value, err)
+	}
+	return minISR, nil
+}
+
+// describeMinISRViolations lists the partitions of the given topics, or of
+// all topics if none are given, which have fewer in-sync replicas than
+// min.insync.replicas.
+func describeMinISRViolations(names []string) {
+	if topicOutputFlag != "default" && topicOutputFlag != "json" {
+		errorExit("Invalid output format %v\n", topicOutputFlag)
+	}
+	if concurrencyFlag < 1 {
+		errorExit("--concurrency must be at least 1\n")
+	}
+
+	admin := getClusterAdmin()
+	if len(names) == 0 {
+		topics, err := admin.ListTopics()
+		if err != nil {
+			errorExit("Unable to list topics: %v\n", err)
+		}
+		for name := range topics {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	metadata, err := admin.DescribeTopics(names)
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+	for _, meta := range metadata {
+		if meta.Err != sarama.ErrNoError {
+			errorExit("Unable to describe topic %v: %v\n", meta.Name, meta.Err)
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		violations = []minISRViolation{}
+		wg         sync.WaitGroup
+	)
+	jobs := make(chan *sarama.TopicMetadata)
+	for i := 0; i < concurrencyFlag; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for meta := range jobs {
+				minISR, err := topicMinISR(admin, meta.Name)
+				if err != nil {
+					errorExit("Unable to describe config of topic %v: %v\n", meta.Name, err)
+				}
+				for _, partition := range meta.Partitions {
+					if len(partition.Isr) >= minISR {
+						continue
+					}
+					mu.Lock()
+					violations = append(violations, minISRViolation{meta.Name, partition.ID, len(partition.Isr), minISR, len(partition.Replicas)})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, meta := range metadata {
+		jobs <- meta
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Topic != violations[j].Topic {
+			return violations[i].Topic < violations[j].Topic
+		}
+		return violations[i].Partition < violations[j].Partition
+	})
+
+	if topicOutputFlag == "json" {
+		b, err := json.MarshalIndent(violations, "", "  ")
+		if err != nil {
+			errorExit("Unable to encode violations: %v\n", err)
+		}
+		fmt.Println(string(b))
+	} else if len(violations) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "TOPIC\tPARTITION\tISR\tMIN-ISR\tREPLICAS\t\n")
+		for _, v := range violations {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t\n", v.Topic, v.Partition, v.ISR, v.MinISR, v.Replicas)
+		}
+		w.Flush()
+	} else if !checkFlag || verbose {
+		fmt.Println("No partition has fewer in-sync replicas than min.insync.replicas.")
+	}
+
+	if checkFlag && len(violations) > 0 {
+		errorExit("%v partitions have fewer in-sync replicas than min.insync.replicas\n", len(violations))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	purgeBeforeFlag string
+	yesFlag         bool
+)
+
+func init() {
+	topicCmd.AddCommand(purgeTopicCmd)
+
+	purgeTopicCmd.Flags().StringVar(&purgeBeforeFlag, "before", "", "Delete all records with a timestamp before this time, in RFC3339 format (e.g. 2019-07-01T12:00:00Z)")
+	purgeTopicCmd.Flags().BoolVar(&yesFlag, "yes", false, "Confirm the deletion of records")
+}
+
+var purgeTopicCmd = &cobra.Command{
+	Use:   "purge-older-than TOPIC",
+	Short: "Delete all records of a topic older than a given time",
+	Long:  "Delete all records of a topic older than a given time, regardless of the retention configured for the topic. For each partition, all records before the first record with a timestamp at or after --before are deleted.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		topic := args[0]
+
+		if purgeBeforeFlag == "" {
+			errorExit("The --before flag is required\n")
+		}
+		before, err := parseTimestamp(purgeBeforeFlag)
+		if err != nil {
+			errorExit("Unable to parse time: %v\n", err)
+		}
+		if !yesFlag {
+			errorExit("Deleting records can not be undone, confirm with --yes\n")
+		}
+
+		checkFeature(featureDeleteRecords)
+
+		client := getClient()
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			errorExit("Unable to get partitions: %v\n", err)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		oldest := getOldestOffsetsFromClient(client, topic, partitions)
+		highWatermarks := getHighWatermarksFromClient(client, topic, partitions)
+		cutoffs := getOffsetsFromClient(client, topic, partitions, timeToMillis(before))
+
+		deleteOffsets := make(map[int32]int64)
+		for _, partition := range partitions {
+			cutoff := cutoffs[partition]
+			if cutoff < 0 {
+				// All records are older than --before. Errors of the
+				// offset request were rejected by getOffsetsFromClient.
+				cutoff = highWatermarks[partition]
+			}
+			if cutoff > oldest[partition] {
+				deleteOffsets[partition] = cutoff
+			}
+		}
+
+		if len(deleteOffsets) > 0 {
+			admin := getClusterAdmin()
+			if err := admin.DeleteRecords(topic, deleteOffsets); err != nil {
+				errorExit("Unable to delete records: %v\n", err)
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "PARTITION\tOLDEST OFFSET\tDELETED RECORDS\t\n")
+		for _, partition := range partitions {
+			newOldest, ok := deleteOffsets[partition]
+			if !ok {
+				newOldest = oldest[partition]
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", partition, newOldest, newOldest-oldest[partition])
+		}
+		w.Flush()
+	},
+}
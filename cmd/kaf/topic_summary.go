@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var summaryFlag bool
+
+func init() {
+	describeTopicCmd.Flags().BoolVar(&summaryFlag, "summary", false, "Print one line per topic with partitions, replicas, retained messages, under-replicated partitions and compaction. Without a topic, all topics are summarized")
+	describeTopicCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers of --summary")
+	describeTopicCmd.Flags().IntVar(&concurrencyFlag, "concurrency", 8, "Number of topics summarized in parallel with --summary")
+}
+
+// topicSummary is a single line of topic describe --summary.
+type topicSummary struct {
+	Name            string `json:"name"`
+	Partitions      int    `json:"partitions"`
+	Replicas        int    `json:"replicas"`
+	Messages        int64  `json:"messages"`
+	UnderReplicated int    `json:"under_replicated"`
+	Compacted       bool   `json:"compacted"`
+	Error           string `json:"error,omitempty"`
+}
+
+// describeTopicSummaries prints a summary of the given topics, or of all
+// topics if none are given.
+func describeTopicSummaries(names []string) {
+	if topicOutputFlag != "default" && topicOutputFlag != "json" {
+		errorExit("Invalid output format %v\n", topicOutputFlag)
+	}
+	if concurrencyFlag < 1 {
+		errorExit("--concurrency must be at least 1\n")
+	}
+
+	admin := getClusterAdmin()
+	client := getClient()
+
+	topics, err := admin.ListTopics()
+	if err != nil {
+		errorExit("Unable to list topics: %v\n", err)
+	}
+	if len(names) == 0 {
+		for name := range topics {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	metadata, err := admin.DescribeTopics(names)
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+
+	summaries := make([]topicSummary, len(metadata))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrencyFlag; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				meta := metadata[j]
+				summary := &summaries[j]
+				summary.Name = meta.Name
+				if meta.Err != sarama.ErrNoError {
+					summary.Error = meta.Err.Error()
+					continue
+				}
+
+				partitions := make([]int32, 0, len(meta.Partitions))
+				for _, partition := range meta.Partitions {
+					partitions = append(partitions, partition.ID)
+					if len(partition.Isr) < len(partition.Replicas) {
+						summary.UnderReplicated++
+					}
+				}
+				summary.Partitions = len(partitions)
+				if len(meta.Partitions) > 0 {
+					summary.Replicas = len(meta.Partitions[0].Replicas)
+				}
+				if policy := topics[meta.Name].ConfigEntries["cleanup.policy"]; policy != nil {
+					summary.Compacted = strings.Contains(*policy, "compact")
+				}
+
+				oldest := getOldestOffsetsFromClient(client, meta.Name, partitions)
+				highWatermarks := getHighWatermarksFromClient(client, meta.Name, partitions)
+				for _, partition := range partitions {
+					summary.Messages += highWatermarks[partition] - oldest[partition]
+				}
+			}
+		}()
+	}
+	for i := range metadata {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+	out, done := startPager()
+	defer done()
+
+	if topicOutputFlag == "json" {
+		for _, summary := range summaries {
+			b, err := json.Marshal(summary)
+			if err != nil {
+				errorExit("Unable to marshal topic summary: %v\n", err)
+			}
+			fmt.Fprintln(out, string(b))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(out, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\tMESSAGES\tUNDER-REPLICATED\tCOMPACTED\t\n")
+	}
+	for _, s := range summaries {
+		if s.Error != "" {
+			fmt.Fprintf(w, "%v\terror: %v\t\t\t\t\t\n", s.Name, s.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t\n", s.Name, s.Partitions, s.Replicas, s.Messages, s.UnderReplicated, s.Compacted)
+	}
+	w.Flush()
+}
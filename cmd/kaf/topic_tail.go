@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// defaultTailMessages is the number of messages per partition topic tail
+// prints before following new ones.
+const defaultTailMessages = "10"
+
+func init() {
+	topicCmd.AddCommand(topicTailCmd)
+}
+
+var topicTailCmd = &cobra.Command{
+	Use:   "tail TOPIC [consume flags]",
+	Short: "Print the last messages of a topic and follow new ones",
+	Long:  "Print the last 10 messages of each partition of a topic and follow new ones. Shorthand for consume TOPIC --tail 10, all flags of consume are accepted and take precedence.",
+	// Flags are parsed by consume.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		rootCmd.SetArgs(append([]string{"consume", "--tail", defaultTailMessages}, args...))
+		if err := rootCmd.Execute(); err != nil {
+			errorExit("%v\n", err)
+		}
+	},
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	validateJSONFlag bool
+
+	validatedMessages   int64
+	invalidJSONMessages int64
+)
+
+func init() {
+	consumeCmd.Flags().BoolVar(&validateJSONFlag, "validate-json", false, "Instead of printing messages, print partition, offset and parse error of each message whose decoded value is not valid JSON. Null values are skipped. Exits with code 1 if any value was invalid. Combine with --limit to bound the scan")
+}
+
+// invalidJSONMessage is a line of --validate-json with --output json.
+type invalidJSONMessage struct {
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Error     string `json:"error"`
+}
+
+// validateJSON reports msg if its decoded value is not valid JSON.
+func validateJSON(msg *sarama.ConsumerMessage, value []byte) {
+	if msg.Value == nil {
+		return
+	}
+	atomic.AddInt64(&validatedMessages, 1)
+
+	var v interface{}
+	err := json.Unmarshal(value, &v)
+	if err == nil {
+		return
+	}
+	atomic.AddInt64(&invalidJSONMessages, 1)
+
+	var line []byte
+	if outputFlag == "json" {
+		line, _ = json.Marshal(invalidJSONMessage{msg.Partition, msg.Offset, err.Error()})
+		line = append(line, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("Partition %v offset %v: %v\n", msg.Partition, msg.Offset, err))
+	}
+	output.write(msg.Partition, nil, line)
+}
+
+// printValidateJSONSummary prints the totals of --validate-json.
+func printValidateJSONSummary() {
+	if !validateJSONFlag {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Validated %v values, %v invalid JSON.\n", atomic.LoadInt64(&validatedMessages), atomic.LoadInt64(&invalidJSONMessages))
+}
+
+// exitOnInvalidJSON exits with code 1 if --validate-json found invalid values.
+func exitOnInvalidJSON() {
+	if atomic.LoadInt64(&invalidJSONMessages) > 0 {
+		os.Exit(1)
+	}
+}
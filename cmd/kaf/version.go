@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+// feature is a capability of kaf which requires a minimum Kafka version.
+type feature struct {
+	name       string
+	minVersion sarama.KafkaVersion
+}
+
+var (
+	featureTimestamps       = feature{"Record timestamps", sarama.V0_10_0_0}
+	featureHeaders          = feature{"Record headers", sarama.V0_11_0_0}
+	featureDeleteRecords    = feature{"DeleteRecords", sarama.V0_11_0_0}
+	featureCreatePartitions = feature{"Adding partitions", sarama.V1_0_0_0}
+	featureZstd             = feature{"zstd compression", sarama.V2_1_0_0}
+)
+
+// features lists all features with a minimum Kafka version, as printed by
+// kaf version.
+var features = []feature{
+	featureTimestamps,
+	featureHeaders,
+	featureDeleteRecords,
+	featureCreatePartitions,
+	featureZstd,
+}
+
+// requireFeature returns an error if f is not supported by the Kafka version
+// configured in cfg.
+func requireFeature(cfg *sarama.Config, f feature) error {
+	if cfg.Version.IsAtLeast(f.minVersion) {
+		return nil
+	}
+	return fmt.Errorf("%v requires Kafka %v or later, the configured version is %v. Set kafka-version in the cluster config", f.name, f.minVersion, cfg.Version)
+}
+
+// checkFeature exits if f is not supported by the Kafka version of the
+// current cluster.
+func checkFeature(f feature) {
+	if err := requireFeature(getConfig(), f); err != nil {
+		errorExit("%v\n", err)
+	}
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of kaf and the Kafka version it uses for the current cluster",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getConfig()
+
+		fmt.Printf("kaf %v\n", version)
+		source := "default, set kafka-version in the cluster config"
+		if currentCluster.KafkaVersion != "" {
+			source = "from cluster config"
+		}
+		fmt.Printf("Kafka version: %v (%v)\n\n", cfg.Version, source)
+
+		w := tabwriter.NewWriter(os.Stdout, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "FEATURE\tREQUIRES\tAVAILABLE\t\n")
+		for _, f := range features {
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", f.name, f.minVersion, cfg.Version.IsAtLeast(f.minVersion))
+		}
+		w.Flush()
+	},
+}
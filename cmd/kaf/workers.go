@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	workersFlag    int
+	assignmentFlag string
+
+	// partitionWorkers maps partitions to the simulated worker consuming
+	// them with --workers.
+	partitionWorkers map[int32]int
+)
+
+func init() {
+	consumeCmd.Flags().IntVar(&workersFlag, "workers", 0, "Simulate this many workers of a consumer group, splitting the partitions among them with --assignment and labeling each message with its worker. The assignment is computed locally, no group is joined")
+	consumeCmd.Flags().StringVar(&assignmentFlag, "assignment", "range", "Assignment strategy of --workers. Possible values: range, roundrobin")
+}
+
+// workerID returns the member ID of worker i. IDs are zero-padded, as
+// strategies order members by ID.
+func workerID(i int) string {
+	return fmt.Sprintf("worker-%0*d", len(fmt.Sprint(workersFlag-1)), i)
+}
+
+// assignWorkers splits partitions among --workers workers like a group using
+// the --assignment strategy would.
+func assignWorkers(topic string, partitions []int32) ([][]int32, error) {
+	strategy, err := parseRebalanceStrategy(assignmentFlag)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for --assignment: %v. Possible values: range, roundrobin", assignmentFlag)
+	}
+
+	members := make(map[string]sarama.ConsumerGroupMemberMetadata, workersFlag)
+	ids := make(map[string]int, workersFlag)
+	for i := 0; i < workersFlag; i++ {
+		members[workerID(i)] = sarama.ConsumerGroupMemberMetadata{Topics: []string{topic}}
+		ids[workerID(i)] = i
+	}
+	plan, err := strategy.Plan(members, map[string][]int32{topic: partitions})
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := make([][]int32, workersFlag)
+	for member, topics := range plan {
+		assigned := topics[topic]
+		sort.Slice(assigned, func(i, j int) bool { return assigned[i] < assigned[j] })
+		assignment[ids[member]] = assigned
+	}
+	return assignment, nil
+}
+
+// printWorkerAssignment prints the partitions of each worker to stderr.
+func printWorkerAssignment(assignment [][]int32) {
+	w := tabwriter.NewWriter(os.Stderr, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "WORKER\tPARTITIONS\t\n")
+	for i, partitions := range assignment {
+		fmt.Fprintf(w, "%v\t%v\t\n", i, partitions)
+	}
+	w.Flush()
+}
+
+// consumeWorkers consumes the partitions of each simulated worker
+// concurrently.
+func consumeWorkers(client sarama.Client, topic string, partitions []int32, opts readOptions, emit func(*sarama.ConsumerMessage)) error {
+	assignment, err := assignWorkers(topic, partitions)
+	if err != nil {
+		return err
+	}
+	printWorkerAssignment(assignment)
+
+	partitionWorkers = make(map[int32]int, len(partitions))
+	for i, assigned := range assignment {
+		for _, partition := range assigned {
+			partitionWorkers[partition] = i
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, assigned := range assignment {
+		if len(assigned) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(assigned []int32) {
+			defer wg.Done()
+			if err := consumePartitions(client, topic, assigned, opts, emit); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(assigned)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -13,25 +13,101 @@ type SASL struct {
 	Mechanism string
 	Username  string
 	Password  string
+	// OAuth provides the access token of the OAUTHBEARER mechanism.
+	OAuth *OAuth `yaml:"oauth,omitempty"`
+}
+
+// OAuth configures where OAUTHBEARER access tokens come from. Exactly one of
+// Token, TokenCommand or TokenEndpoint is used.
+type OAuth struct {
+	// Token is a static access token.
+	Token string `yaml:"token,omitempty"`
+	// TokenCommand is a shell command printing an access token.
+	TokenCommand string `yaml:"token-command,omitempty"`
+	// TokenEndpoint is the URL of the token endpoint of an OAuth 2.0
+	// server, from which tokens are fetched with the client credentials
+	// grant.
+	TokenEndpoint string   `yaml:"token-endpoint,omitempty"`
+	ClientID      string   `yaml:"client-id,omitempty"`
+	ClientSecret  string   `yaml:"client-secret,omitempty"`
+	Scopes        []string `yaml:"scopes,omitempty"`
 }
 
 type TLS struct {
-	Cafile   string
-	Insecure bool
+	Cafile        string
+	Clientfile    string
+	Clientkeyfile string
+	Insecure      bool
+	// ServerName is the host name broker certificates are verified
+	// against, if brokers are addressed by another name, e.g. an IP.
+	ServerName string `yaml:"server-name,omitempty"`
+}
+
+// TopicNamingPolicy restricts the names of topics created with kaf.
+type TopicNamingPolicy struct {
+	// Pattern is a regular expression topic names must match.
+	Pattern string `yaml:"pattern"`
+	// MaxLength limits the length of topic names, if greater than zero.
+	MaxLength int `yaml:"max-length"`
+	// Description is shown to the user if a name violates the policy.
+	Description string `yaml:"description"`
 }
 
 type Cluster struct {
 	Name              string
-	Brokers           []string `yaml:"brokers"`
-	SASL              *SASL    `yaml:"SASL"`
-	TLS               *TLS     `yaml:"TLS"`
-	SecurityProtocol  string   `yaml:"security-protocol"`
-	SchemaRegistryURL string   `yaml:"schema-registry-url"`
+	Brokers           []string           `yaml:"brokers"`
+	SASL              *SASL              `yaml:"SASL"`
+	TLS               *TLS               `yaml:"TLS"`
+	SecurityProtocol  string             `yaml:"security-protocol"`
+	SchemaRegistryURL string             `yaml:"schema-registry-url"`
+	TopicNamingPolicy *TopicNamingPolicy `yaml:"topic-naming-policy,omitempty"`
+	// KafkaVersion is the Kafka version of the cluster, e.g. 2.1.0. Newer
+	// features of the protocol are only used if it is recent enough.
+	KafkaVersion string `yaml:"kafka-version,omitempty"`
+}
+
+// Clone returns a deep copy of c.
+func (c *Cluster) Clone() *Cluster {
+	clone := *c
+	clone.Brokers = append([]string(nil), c.Brokers...)
+	if c.SASL != nil {
+		sasl := *c.SASL
+		if c.SASL.OAuth != nil {
+			oauth := *c.SASL.OAuth
+			oauth.Scopes = append([]string(nil), c.SASL.OAuth.Scopes...)
+			sasl.OAuth = &oauth
+		}
+		clone.SASL = &sasl
+	}
+	if c.TLS != nil {
+		tls := *c.TLS
+		clone.TLS = &tls
+	}
+	if c.TopicNamingPolicy != nil {
+		policy := *c.TopicNamingPolicy
+		clone.TopicNamingPolicy = &policy
+	}
+	return &clone
+}
+
+// Defaults are per-user defaults of command line flags. Flags given on the
+// command line take precedence.
+type Defaults struct {
+	Consume ConsumeDefaults `yaml:"consume,omitempty"`
+	// Color enables colored output if unset or true.
+	Color *bool `yaml:"color,omitempty"`
+}
+
+// ConsumeDefaults are the defaults of the consume command.
+type ConsumeDefaults struct {
+	Offset string `yaml:"offset,omitempty"`
+	Output string `yaml:"output,omitempty"`
 }
 
 type Config struct {
 	CurrentCluster string     `yaml:"current-cluster"`
 	Clusters       []*Cluster `yaml:"clusters"`
+	Defaults       *Defaults  `yaml:"defaults,omitempty"`
 }
 
 func (c *Config) SetCurrentCluster(name string) error {
@@ -62,8 +138,18 @@ func (c *Config) ActiveCluster() *Cluster {
 		return nil
 	}
 
+	return c.Cluster(c.CurrentCluster)
+}
+
+// Cluster returns the cluster with the given name, or nil if it is not
+// configured.
+func (c *Config) Cluster(name string) *Cluster {
+	if c == nil {
+		return nil
+	}
+
 	for _, cluster := range c.Clusters {
-		if cluster.Name == c.CurrentCluster {
+		if cluster.Name == name {
 			return cluster
 		}
 	}
@@ -0,0 +1,50 @@
+package kaf
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxTopicNameLength is the maximum length of a topic name accepted by Kafka.
+const maxTopicNameLength = 249
+
+var legalTopicName = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// ValidateTopicName checks whether name is a legal Kafka topic name and
+// complies with the given policy, which may be nil.
+func ValidateTopicName(name string, policy *TopicNamingPolicy) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("topic name %q is not allowed", name)
+	}
+	if len(name) > maxTopicNameLength {
+		return fmt.Errorf("topic name is longer than %v characters", maxTopicNameLength)
+	}
+	if !legalTopicName.MatchString(name) {
+		return fmt.Errorf("topic name %q contains characters other than ASCII alphanumerics, '.', '_' and '-'", name)
+	}
+
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxLength > 0 && len(name) > policy.MaxLength {
+		return policyError(name, policy, fmt.Sprintf("longer than %v characters", policy.MaxLength))
+	}
+	if policy.Pattern != "" {
+		re, err := regexp.Compile(policy.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid topic naming policy pattern: %v", err)
+		}
+		if !re.MatchString(name) {
+			return policyError(name, policy, fmt.Sprintf("does not match %v", policy.Pattern))
+		}
+	}
+	return nil
+}
+
+func policyError(name string, policy *TopicNamingPolicy, reason string) error {
+	if policy.Description != "" {
+		return fmt.Errorf("topic name %q violates the naming policy (%v): %v", name, reason, policy.Description)
+	}
+	return fmt.Errorf("topic name %q violates the naming policy: %v", name, reason)
+}